@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/apex/log"
+	"github.com/cedws/fiat2xmr/fiat2xmr"
+	"github.com/spf13/cobra"
+)
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume an unfinished conversion from the journal",
+	Run: func(cmd *cobra.Command, args []string) {
+		cnv := newConverter(cmd.Context())
+		result, err := cnv.Resume(cmd.Context(), opts)
+		if errors.Is(err, fiat2xmr.ErrNothingToResume) {
+			log.Infof("%v", err)
+			return
+		}
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		logResult(result)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+}
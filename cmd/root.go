@@ -1,15 +1,37 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/text"
 	"github.com/cedws/fiat2xmr/coinbase"
 	"github.com/cedws/fiat2xmr/fiat2xmr"
 	"github.com/cedws/fiat2xmr/sideshift"
+	"github.com/cedws/fiat2xmr/walletrpc"
+	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
 )
 
-var opts fiat2xmr.Opts
+var (
+	opts               fiat2xmr.Opts
+	sendFees           map[string]string
+	reserve            string
+	shiftAmount        string
+	coinbaseSecretFile string
+	walletRPC          string
+	totpSecretFile     string
+	twoFactorPrompt    bool
+	travelRuleFile     string
+)
 
 var rootCmd = &cobra.Command{
 	Use: "fiat2xmr",
@@ -17,34 +39,177 @@ var rootCmd = &cobra.Command{
 		log.SetHandler(text.Default)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		ssClient := sideshift.NewClient(opts.SideShiftSecret)
-		if canShift, err := ssClient.CanShift(); !canShift || err != nil {
+		opts.SendFees = make(map[string]decimal.Decimal, len(sendFees))
+		for currency, fee := range sendFees {
+			parsed, err := decimal.NewFromString(fee)
 			if err != nil {
-				log.Fatalf("%+v", err)
+				log.Fatalf("invalid send fee for %v: %v", currency, err)
 			}
-			log.Fatal("sideshift account is unable to create shifts")
+			opts.SendFees[currency] = parsed
 		}
-		cbClient := coinbase.NewClient(opts.CoinbaseKey, opts.CoinbaseSecret)
 
-		cnv := fiat2xmr.NewConverter(ssClient, cbClient)
-		cnv.Convert(opts)
+		var err error
+		if reserve != "" {
+			if opts.Reserve, err = decimal.NewFromString(reserve); err != nil {
+				log.Fatalf("invalid reserve: %v", err)
+			}
+		}
+		if shiftAmount != "" {
+			if opts.ShiftAmount, err = decimal.NewFromString(shiftAmount); err != nil {
+				log.Fatalf("invalid shift amount: %v", err)
+			}
+		}
+
+		if opts.Address == "" {
+			log.Fatal("--address is required")
+		}
+
+		cnv := newConverter(cmd.Context())
+		result, err := cnv.Convert(cmd.Context(), opts)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		logResult(result)
 	},
 }
 
+func logResult(result *fiat2xmr.Result) {
+	if result.PaymentID != "" {
+		log.Infof("settling to %v with payment ID %v", result.Address, result.PaymentID)
+	}
+	if result.Fill != nil {
+		log.Infof("bought %v %v at an average price of %v, paying %v in fees", result.Fill.FilledSize, result.Currencies.Base, result.Fill.AveragePrice, result.Fill.Fees)
+	}
+	for _, shift := range result.Shifts {
+		log.Infof("%+v", shift)
+	}
+	if len(result.Shifts) > 0 {
+		log.Infof("settled %v %v in total", result.SettleAmount, result.Currencies.Quote)
+	}
+}
+
+func newConverter(ctx context.Context) *fiat2xmr.Converter {
+	ssClient := sideshift.NewClient(opts.SideShiftSecret)
+	if canShift, err := ssClient.CanShift(ctx); !canShift || err != nil {
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		log.Fatal("sideshift account is unable to create shifts")
+	}
+
+	if coinbaseSecretFile != "" {
+		secret, err := os.ReadFile(coinbaseSecretFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		opts.CoinbaseSecret = strings.TrimSpace(string(secret))
+	}
+	if opts.CoinbaseSecret == "" {
+		log.Fatal("one of --coinbase-secret or --coinbase-secret-file is required")
+	}
+
+	cbAuth, err := coinbase.NewAuthenticator(opts.CoinbaseKey, opts.CoinbaseSecret)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	cbClient := coinbase.NewClientWithAuth(cbAuth)
+
+	switch {
+	case totpSecretFile != "":
+		secret, err := os.ReadFile(totpSecretFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if opts.TwoFactor, err = coinbase.NewTOTPSource(strings.TrimSpace(string(secret))); err != nil {
+			log.Fatalf("%v", err)
+		}
+	case twoFactorPrompt:
+		opts.TwoFactor = promptTwoFactor
+	}
+
+	if travelRuleFile != "" {
+		file, err := os.Open(travelRuleFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		defer file.Close()
+
+		opts.TravelRule = &coinbase.TravelRuleData{}
+		if err := json.NewDecoder(file).Decode(opts.TravelRule); err != nil {
+			log.Fatalf("invalid travel rule file: %v", err)
+		}
+	}
+
+	if walletRPC != "" {
+		return fiat2xmr.NewConverterWithWallet(ssClient, cbClient, walletrpc.NewClient(walletRPC))
+	}
+	return fiat2xmr.NewConverter(ssClient, cbClient)
+}
+
+// concurrent shifts may each need a token, so only prompt for one at a time
+var promptMu sync.Mutex
+
+func promptTwoFactor(ctx context.Context) (string, error) {
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	fmt.Fprint(os.Stderr, "coinbase 2FA token: ")
+	token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("while reading 2FA token: %w", err)
+	}
+	return strings.TrimSpace(token), nil
+}
+
 func init() {
-	rootCmd.Flags().StringVar(&opts.CoinbaseKey, "coinbase-key", "", "coinbase account key")
-	rootCmd.Flags().StringVar(&opts.CoinbaseSecret, "coinbase-secret", "", "coinbase account secret")
-	rootCmd.Flags().StringVar(&opts.SideShiftSecret, "sideshift-secret", "", "sideshift account secret")
+	rootCmd.PersistentFlags().StringVar(&opts.CoinbaseKey, "coinbase-key", "", "coinbase account key, or key name for CDP keys")
+	rootCmd.PersistentFlags().StringVar(&opts.CoinbaseSecret, "coinbase-secret", "", "coinbase account secret, or PEM private key for CDP keys")
+	rootCmd.PersistentFlags().StringVar(&coinbaseSecretFile, "coinbase-secret-file", "", "file to read the coinbase account secret from")
+	rootCmd.PersistentFlags().StringVar(&opts.SideShiftSecret, "sideshift-secret", "", "sideshift account secret")
+	rootCmd.PersistentFlags().StringVar(&opts.JournalPath, "journal", "fiat2xmr.json", "path to conversion state journal")
 	rootCmd.Flags().StringVarP(&opts.Address, "address", "x", "", "monero wallet address")
+	rootCmd.Flags().StringVar(&opts.PaymentID, "payment-id", "", "hex payment ID to build an integrated address from a standard --address")
+	rootCmd.Flags().StringVar(&opts.ViewKey, "view-key", "", "hex private view key of the --address wallet, to settle every conversion to a fresh subaddress")
+	rootCmd.PersistentFlags().Uint32Var(&opts.SubaddressAccount, "subaddress-account", 0, "account (major index) to derive subaddresses in and to look for payouts in with --wallet-rpc")
+	rootCmd.Flags().StringVar(&opts.SubaddressStatePath, "subaddress-state", "fiat2xmr-subaddress.json", "path to the file keeping the next unused subaddress index")
+	rootCmd.Flags().StringVar(&opts.Currencies.Fiat, "fiat-currency", fiat2xmr.DefaultFiatCurrency, "fiat currency to convert from")
+	rootCmd.Flags().StringVar(&opts.Currencies.Base, "base-currency", fiat2xmr.DefaultBaseCurrency, "intermediate currency to buy on coinbase and shift")
+	rootCmd.Flags().StringVar(&opts.Currencies.Quote, "quote-currency", fiat2xmr.DefaultQuoteCurrency, "currency to settle the shift in")
+	rootCmd.Flags().StringVar(&opts.Currencies.BaseNetwork, "base-network", "", "network to deposit the base currency on, for coins on more than one chain")
+	rootCmd.Flags().StringVar(&opts.Currencies.QuoteNetwork, "quote-network", "", "network to settle the quote currency on, for coins on more than one chain")
+	rootCmd.Flags().StringSliceVar(&opts.BridgeCandidates, "bridge-candidates", nil, "candidate base currencies to pick the cheapest route from (overrides --base-currency)")
+	rootCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "log the order, shift and send that would be made without making them")
+	rootCmd.Flags().StringVar(&opts.ShiftType, "shift-type", fiat2xmr.ShiftTypeFixed, "fixed to shift at a quoted rate, or variable to take the rate when the deposit arrives")
+	rootCmd.PersistentFlags().BoolVar(&opts.ConcurrentShifts, "concurrent-shifts", false, "run shifts concurrently when the balance is split across several")
+	rootCmd.Flags().BoolVar(&opts.Maker, "maker", false, "buy with post-only limit orders to pay maker fees, falling back to market after --maker-timeout")
+	rootCmd.Flags().DurationVar(&opts.MakerRepriceInterval, "maker-reprice-interval", time.Minute, "how long a maker order may rest before it is re-priced")
+	rootCmd.Flags().DurationVar(&opts.MakerTimeout, "maker-timeout", 15*time.Minute, "how long to try maker orders before buying the rest at market")
+	rootCmd.PersistentFlags().StringVar(&totpSecretFile, "coinbase-totp-secret-file", "", "file to read the coinbase authenticator app secret from, for sends that need 2FA")
+	rootCmd.PersistentFlags().BoolVar(&twoFactorPrompt, "coinbase-2fa-prompt", false, "prompt for a 2FA token when a send needs one")
+	rootCmd.PersistentFlags().StringVar(&opts.SendDescription, "send-description", "", "description to attach to sends")
+	rootCmd.PersistentFlags().StringVar(&travelRuleFile, "travel-rule-file", "", "JSON file with travel rule beneficiary details to attach to sends")
+	rootCmd.PersistentFlags().DurationVar(&opts.ShiftPollInterval, "shift-poll-interval", 10*time.Second, "how often to check on a shift once it has been paid")
+	rootCmd.PersistentFlags().DurationVar(&opts.ShiftTimeout, "shift-timeout", 0, "how long to wait for a paid shift to settle, 0 to wait indefinitely")
+	rootCmd.PersistentFlags().StringVar(&walletRPC, "wallet-rpc", "", "monero-wallet-rpc JSON-RPC URL to confirm payouts with, e.g. http://127.0.0.1:18082/json_rpc")
+	rootCmd.PersistentFlags().Uint64Var(&opts.Confirmations, "confirmations", 10, "confirmations a payout needs in the wallet before the conversion is complete")
+	rootCmd.Flags().StringToStringVar(&sendFees, "send-fee", nil, "estimated network fee for sends per currency, e.g. LTC=0.0001")
+	rootCmd.Flags().StringVar(&reserve, "reserve", "", "base currency to leave on coinbase instead of shifting the whole balance")
+	rootCmd.Flags().StringVar(&shiftAmount, "shift-amount", "", "exact amount of base currency to shift, with network fees paid on top")
+	rootCmd.Flags().BoolVar(&opts.ShiftBought, "shift-bought", false, "only shift the base currency bought by this run")
 
-	rootCmd.MarkFlagRequired("coinbase-key")
-	rootCmd.MarkFlagRequired("coinbase-secret")
-	rootCmd.MarkFlagRequired("sideshift-secret")
-	rootCmd.MarkFlagRequired("address")
+	rootCmd.MarkPersistentFlagRequired("coinbase-key")
+	rootCmd.MarkFlagsMutuallyExclusive("coinbase-secret", "coinbase-secret-file")
+	rootCmd.MarkPersistentFlagRequired("sideshift-secret")
+	rootCmd.MarkFlagsMutuallyExclusive("coinbase-totp-secret-file", "coinbase-2fa-prompt")
+	rootCmd.MarkFlagsMutuallyExclusive("shift-amount", "shift-bought")
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// cancel in-flight requests and polling on Ctrl-C, the journal lets us pick up from where we left off
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		log.Fatalf("%+v", err)
 	}
 }
@@ -0,0 +1,155 @@
+package coinbase
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Authenticator adds credentials to a request before it is sent. body is the encoded request body, which is empty for
+// GET requests.
+type Authenticator interface {
+	Authenticate(req *http.Request, body []byte) error
+}
+
+// NewAuthenticator picks the scheme based on the shape of the secret. Cloud Developer Platform keys come with a PEM
+// encoded EC private key, anything else is treated as a legacy HMAC secret.
+func NewAuthenticator(apiKey, apiSecret string) (Authenticator, error) {
+	if strings.Contains(apiSecret, "-----BEGIN") {
+		return NewJWTAuth(apiKey, apiSecret)
+	}
+	return NewHMACAuth(apiKey, apiSecret), nil
+}
+
+// HMACAuth signs requests with the legacy CB-ACCESS-SIGN scheme.
+type HMACAuth struct {
+	apiKey    string
+	apiSecret string
+}
+
+func NewHMACAuth(apiKey, apiSecret string) *HMACAuth {
+	return &HMACAuth{apiKey, apiSecret}
+}
+
+func (a *HMACAuth) Authenticate(req *http.Request, body []byte) error {
+	timestamp := fmt.Sprintf("%v", timeNow().Unix())
+
+	hmac := hmac.New(sha256.New, []byte(a.apiSecret))
+	hmac.Write([]byte(timestamp))
+	hmac.Write([]byte(req.Method))
+	hmac.Write([]byte(req.URL.Path))
+	hmac.Write(body)
+
+	req.Header.Set("CB-ACCESS-KEY", a.apiKey)
+	req.Header.Set("CB-ACCESS-SIGN", hex.EncodeToString(hmac.Sum(nil)))
+	req.Header.Set("CB-ACCESS-TIMESTAMP", timestamp)
+
+	return nil
+}
+
+// JWTAuth signs each request with a short-lived ES256 JWT as required by Cloud Developer Platform API keys.
+type JWTAuth struct {
+	keyName    string
+	privateKey *ecdsa.PrivateKey
+}
+
+// NewJWTAuth takes the key name (organizations/{org_id}/apiKeys/{key_id}) and the PEM encoded EC private key issued
+// with it.
+func NewJWTAuth(keyName, privateKeyPEM string) (*JWTAuth, error) {
+	// keys downloaded as JSON have their newlines escaped
+	privateKeyPEM = strings.ReplaceAll(privateKeyPEM, `\n`, "\n")
+
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	var privateKey *ecdsa.PrivateKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		privateKey = key
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		ecKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("private key is not an EC key")
+		}
+		privateKey = ecKey
+	default:
+		return nil, fmt.Errorf("unsupported private key type %v", block.Type)
+	}
+
+	return &JWTAuth{keyName, privateKey}, nil
+}
+
+func (a *JWTAuth) Authenticate(req *http.Request, body []byte) error {
+	token, err := a.token(fmt.Sprintf("%v %v%v", req.Method, req.URL.Host, req.URL.Path))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *JWTAuth) token(uri string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{
+		"alg":   "ES256",
+		"typ":   "JWT",
+		"kid":   a.keyName,
+		"nonce": hex.EncodeToString(nonce),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	now := timeNow().Unix()
+	claims, err := json.Marshal(map[string]any{
+		"iss": "cdp",
+		"sub": a.keyName,
+		"nbf": now,
+		"exp": now + 120,
+		"uri": uri,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	r, s, err := ecdsa.Sign(rand.Reader, a.privateKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// JWS wants the raw fixed-width r || s rather than ASN.1
+	size := (a.privateKey.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -1,10 +1,10 @@
 package coinbase
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -19,23 +19,62 @@ var (
 	timeNow       = time.Now
 )
 
+// ErrTwoFactorRequired is returned when a send needs a 2FA token. The send can be made again with TwoFactorToken set.
+var ErrTwoFactorRequired = errors.New("two factor token required")
+
+// StatusError is returned when Coinbase responds with a non-2xx status.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status code %v", e.Code)
+}
+
+// headerer is implemented by request bodies that need extra headers sent along with them.
+type headerer interface {
+	header() http.Header
+}
+
+func (r *TxRequest) header() http.Header {
+	if r.TwoFactorToken == "" {
+		return nil
+	}
+	return http.Header{"CB-2FA-TOKEN": {r.TwoFactorToken}}
+}
+
 type Client struct {
-	client    *http.Client
-	apiKey    string
-	apiSecret string
+	client *http.Client
+	auth   Authenticator
 }
 
-func requestV2[T any, U any](c *Client, method, endpoint string, body *T) (*U, error) {
-	url := coinbaseV2.JoinPath(endpoint)
+// resolve joins endpoint onto base, keeping any query string in endpoint intact.
+func resolve(base *url.URL, endpoint string) *url.URL {
+	ref, err := url.Parse(endpoint)
+	if err != nil {
+		// should not happen
+		panic(err)
+	}
+
+	resolved := base.JoinPath(ref.Path)
+	resolved.RawQuery = ref.RawQuery
+	return resolved
+}
+
+func requestV2[T any, U any](ctx context.Context, c *Client, method, endpoint string, body *T) (*U, error) {
+	url := resolve(coinbaseV2, endpoint)
 	resp, err := request[T, struct {
 		Errors []struct {
 			ID      string
 			Message string
 		}
 		Data U
-	}](c, method, url, body)
+	}](ctx, c, method, url, body)
 	if err != nil {
 		if resp != nil && len(resp.Errors) > 0 {
+			if resp.Errors[0].ID == "two_factor_required" {
+				return nil, fmt.Errorf("%w (%v)", ErrTwoFactorRequired, resp.Errors[0].Message)
+			}
 			return nil, fmt.Errorf("%w (%v)", err, resp.Errors[0].Message)
 		}
 		return nil, err
@@ -43,49 +82,25 @@ func requestV2[T any, U any](c *Client, method, endpoint string, body *T) (*U, e
 	return &resp.Data, nil
 }
 
-func requestV3[T any, U any](c *Client, method, endpoint string, body *T) (*U, error) {
-	url := coinbaseV3.JoinPath(endpoint)
-	return request[T, U](c, method, url, body)
+func requestV3[T any, U any](ctx context.Context, c *Client, method, endpoint string, body *T) (*U, error) {
+	url := resolve(coinbaseV3, endpoint)
+	return request[T, U](ctx, c, method, url, body)
 }
 
-func request[T any, U any](c *Client, method string, url *url.URL, body *T) (*U, error) {
-	bodyReader, bodyWriter := io.Pipe()
-
-	timestamp := fmt.Sprintf("%v", timeNow().Unix())
-	hmac := hmac.New(sha256.New, []byte(c.apiSecret))
-	hmac.Write([]byte(timestamp))
-	hmac.Write([]byte(method))
-	hmac.Write([]byte(url.Path))
-
+func request[T any, U any](ctx context.Context, c *Client, method string, url *url.URL, body *T) (*U, error) {
+	var encoded bytes.Buffer
 	if body != nil && method != http.MethodGet {
-		go func() error {
-			if err := json.NewEncoder(bodyWriter).Encode(body); err != nil {
-				return bodyWriter.CloseWithError(err)
-			}
-
-			return bodyWriter.Close()
-		}()
-
-		if err := json.NewEncoder(hmac).Encode(body); err != nil {
+		if err := json.NewEncoder(&encoded).Encode(body); err != nil {
 			return nil, err
 		}
-	} else {
-		bodyWriter.Close()
 	}
 
-	req, err := http.NewRequest(method, url.String(), bodyReader)
-	if err != nil {
-		// should not happen
-		panic(err)
+	var header http.Header
+	if body, ok := any(body).(headerer); ok {
+		header = body.header()
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("CB-VERSION", "2022-11-28")
-	req.Header.Set("CB-ACCESS-KEY", c.apiKey)
-	req.Header.Set("CB-ACCESS-SIGN", hex.EncodeToString(hmac.Sum(nil)))
-	req.Header.Set("CB-ACCESS-TIMESTAMP", timestamp)
-
-	res, err := c.client.Do(req)
+	res, err := c.do(ctx, method, url, encoded.Bytes(), header, canRetry(method, body))
 	if err != nil {
 		return nil, err
 	}
@@ -99,96 +114,195 @@ func request[T any, U any](c *Client, method string, url *url.URL, body *T) (*U,
 	}
 
 	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
-		return &decoded, fmt.Errorf("bad status code %v", res.StatusCode)
+		return &decoded, &StatusError{res.StatusCode}
 	}
 
 	return &decoded, nil
 }
 
+// do sends a request, trying again on rate limits, server errors and network failures if retry is set.
+func (c *Client) do(ctx context.Context, method string, url *url.URL, body []byte, header http.Header, retry bool) (*http.Response, error) {
+	return retryPolicy.Do(ctx, retry, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url.String(), bytes.NewReader(body))
+		if err != nil {
+			// should not happen
+			panic(err)
+		}
+
+		for key, values := range header {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("CB-VERSION", "2022-11-28")
+		// signed on every attempt, signatures are only valid for a short while
+		if err := c.auth.Authenticate(req, body); err != nil {
+			return nil, err
+		}
+
+		return c.client.Do(req)
+	})
+}
+
+// NewClient creates a client that signs requests with a legacy HMAC API key.
 func NewClient(apiKey, apiSecret string) *Client {
-	return &Client{&http.Client{}, apiKey, apiSecret}
+	return NewClientWithAuth(NewHMACAuth(apiKey, apiSecret))
+}
+
+func NewClientWithAuth(auth Authenticator) *Client {
+	return &Client{&http.Client{}, auth}
 }
 
-func (c *Client) GetAccountByCode(code string) (*AccountResponse, error) {
+func (c *Client) GetAccountByCode(ctx context.Context, code string) (*AccountResponse, error) {
 	path := fmt.Sprintf("/accounts/%v", url.PathEscape(code))
 
-	result, err := requestV2[struct{}, AccountResponse](c, http.MethodGet, path, nil)
+	result, err := requestV2[struct{}, AccountResponse](ctx, c, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("while getting accounts by code: %w", err)
 	}
 	return result, nil
 }
 
-func (c *Client) GetAccounts() (*AccountsResponse, error) {
-	result, err := requestV2[struct{}, AccountsResponse](c, http.MethodGet, "/accounts", nil)
+func (c *Client) GetAccounts(ctx context.Context) (*AccountsResponse, error) {
+	result, err := requestV2[struct{}, AccountsResponse](ctx, c, http.MethodGet, "/accounts", nil)
 	if err != nil {
 		return nil, fmt.Errorf("while getting accounts: %w", err)
 	}
 	return result, nil
 }
 
-func (c *Client) GetPaymentMethods() (*PaymentMethodsResponse, error) {
-	result, err := requestV2[struct{}, PaymentMethodsResponse](c, http.MethodGet, "/payment-methods", nil)
+func (c *Client) GetPaymentMethods(ctx context.Context) (*PaymentMethodsResponse, error) {
+	result, err := requestV2[struct{}, PaymentMethodsResponse](ctx, c, http.MethodGet, "/payment-methods", nil)
 	if err != nil {
 		return nil, fmt.Errorf("while getting payment methods: %w", err)
 	}
 	return result, nil
 }
 
-func (c *Client) GetAddresses(account string) (*AddressesResponse, error) {
+func (c *Client) GetAddresses(ctx context.Context, account string) (*AddressesResponse, error) {
 	path := fmt.Sprintf("/accounts/%v/addresses", url.PathEscape(account))
 
-	result, err := requestV2[struct{}, AddressesResponse](c, http.MethodGet, path, nil)
+	result, err := requestV2[struct{}, AddressesResponse](ctx, c, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("while getting addresses: %w", err)
 	}
 	return result, nil
 }
 
-func (c *Client) GetProduct(product string) (*ProductResponse, error) {
+func (c *Client) GetProduct(ctx context.Context, product string) (*ProductResponse, error) {
 	path := fmt.Sprintf("/brokerage/products/%v", url.PathEscape(product))
 
-	result, err := requestV3[struct{}, ProductResponse](c, http.MethodGet, path, nil)
+	result, err := requestV3[struct{}, ProductResponse](ctx, c, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("while getting product: %w", err)
 	}
 	return result, nil
 }
 
-func (c *Client) CreateAddress(account string) (*AddressResponse, error) {
+func (c *Client) CreateAddress(ctx context.Context, account string) (*AddressResponse, error) {
 	path := fmt.Sprintf("/accounts/%v/addresses", url.PathEscape(account))
 
-	result, err := requestV2[struct{}, AddressResponse](c, http.MethodPost, path, nil)
+	result, err := requestV2[struct{}, AddressResponse](ctx, c, http.MethodPost, path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("while creating address: %w", err)
 	}
 	return result, nil
 }
 
-func (c *Client) CreateTransaction(account string, transaction TxRequest) (*TxResponse, error) {
+func (c *Client) CreateTransaction(ctx context.Context, account string, transaction TxRequest) (*TxResponse, error) {
 	path := fmt.Sprintf("/accounts/%v/transactions", url.PathEscape(account))
 
-	result, err := requestV2[TxRequest, TxResponse](c, http.MethodPost, path, &transaction)
+	result, err := requestV2[TxRequest, TxResponse](ctx, c, http.MethodPost, path, &transaction)
 	if err != nil {
 		return nil, fmt.Errorf("while creating transaction: %w", err)
 	}
 	return result, nil
 }
 
-func (c *Client) CreateDeposit(account string, deposit DepositRequest) (*DepositResponse, error) {
+// ListTransactions lists the most recent transactions on an account, newest first.
+func (c *Client) ListTransactions(ctx context.Context, account string) (*TransactionsResponse, error) {
+	path := fmt.Sprintf("/accounts/%v/transactions?limit=100", url.PathEscape(account))
+
+	result, err := requestV2[struct{}, TransactionsResponse](ctx, c, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("while listing transactions: %w", err)
+	}
+	return result, nil
+}
+
+func (c *Client) CreateDeposit(ctx context.Context, account string, deposit DepositRequest) (*DepositResponse, error) {
 	path := fmt.Sprintf("/accounts/%v/deposits", url.PathEscape(account))
 
-	result, err := requestV2[DepositRequest, DepositResponse](c, http.MethodPost, path, &deposit)
+	result, err := requestV2[DepositRequest, DepositResponse](ctx, c, http.MethodPost, path, &deposit)
 	if err != nil {
 		return nil, fmt.Errorf("while creating deposit: %w", err)
 	}
 	return result, nil
 }
 
-func (c *Client) CreateAdvancedOrder(order AdvancedOrderRequest) (*AdvancedOrderResponse, error) {
-	result, err := requestV3[AdvancedOrderRequest, AdvancedOrderResponse](c, http.MethodPost, "/brokerage/orders", &order)
+func (c *Client) CreateAdvancedOrder(ctx context.Context, order AdvancedOrderRequest) (*AdvancedOrderResponse, error) {
+	result, err := requestV3[AdvancedOrderRequest, AdvancedOrderResponse](ctx, c, http.MethodPost, "/brokerage/orders", &order)
 	if err != nil {
 		return nil, fmt.Errorf("while creating advanced order: %w", err)
 	}
 	return result, nil
 }
+
+func (c *Client) GetTransactionSummary(ctx context.Context) (*TransactionSummaryResponse, error) {
+	result, err := requestV3[struct{}, TransactionSummaryResponse](ctx, c, http.MethodGet, "/brokerage/transaction_summary", nil)
+	if err != nil {
+		return nil, fmt.Errorf("while getting transaction summary: %w", err)
+	}
+	return result, nil
+}
+
+func (c *Client) GetBestBidAsk(ctx context.Context, product string) (*BestBidAskResponse, error) {
+	path := fmt.Sprintf("/brokerage/best_bid_ask?product_ids=%v", url.QueryEscape(product))
+
+	result, err := requestV3[struct{}, BestBidAskResponse](ctx, c, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("while getting best bid and ask: %w", err)
+	}
+	return result, nil
+}
+
+func (c *Client) GetOrder(ctx context.Context, orderID string) (*OrderResponse, error) {
+	path := fmt.Sprintf("/brokerage/orders/historical/%v", url.PathEscape(orderID))
+
+	result, err := requestV3[struct{}, OrderResponse](ctx, c, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("while getting order: %w", err)
+	}
+	return result, nil
+}
+
+func (c *Client) CancelOrders(ctx context.Context, cancel CancelOrdersRequest) (*CancelOrdersResponse, error) {
+	result, err := requestV3[CancelOrdersRequest, CancelOrdersResponse](ctx, c, http.MethodPost, "/brokerage/orders/batch_cancel", &cancel)
+	if err != nil {
+		return nil, fmt.Errorf("while cancelling orders: %w", err)
+	}
+	return result, nil
+}
+
+func (c *Client) ListFills(ctx context.Context, orderID string) (*FillsResponse, error) {
+	var fills FillsResponse
+
+	query := url.Values{"order_id": {orderID}}
+	for {
+		path := fmt.Sprintf("/brokerage/orders/historical/fills?%v", query.Encode())
+
+		result, err := requestV3[struct{}, FillsResponse](ctx, c, http.MethodGet, path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("while listing fills: %w", err)
+		}
+
+		fills.Fills = append(fills.Fills, result.Fills...)
+		if result.Cursor == "" || len(result.Fills) == 0 {
+			break
+		}
+		query.Set("cursor", result.Cursor)
+	}
+
+	return &fills, nil
+}
@@ -1,9 +1,20 @@
 package coinbase
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,23 +38,24 @@ func TestAccessKey(t *testing.T) {
 	coinbaseV2, _ = url.Parse(srv.URL)
 
 	client := NewClient("123", expectedToken)
-	_, err := client.CreateTransaction("", TxRequest{})
+	_, err := client.CreateTransaction(context.Background(), "", TxRequest{})
 	assert.Nil(t, err)
 }
 
 func TestAccessSign(t *testing.T) {
-	expectedSig := "3e8d467949bb98bc2c9f620aebc822930e2ffbcfd77d09b8b78461a18f5acdf2"
+	// HMAC-SHA256 of "0POST/v2/accounts/transactions{}\n", the path as it is in production
+	expectedSig := "7f12204e16caf128aefa7205c8bfe3480363e6510c42d1d9ac10c9aae20ed8fe"
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, expectedSig, r.Header.Get("CB-ACCESS-SIGN"))
 		w.Write([]byte("{}"))
 	}))
 	defer srv.Close()
 
-	coinbaseV2, _ = url.Parse(srv.URL)
+	coinbaseV2, _ = url.Parse(srv.URL + "/v2")
 	timeNow = fakeTime{}.Now
 
 	client := NewClient("123", "123")
-	_, err := client.CreateTransaction("", TxRequest{})
+	_, err := client.CreateTransaction(context.Background(), "", TxRequest{})
 	assert.Nil(t, err)
 }
 
@@ -58,6 +70,76 @@ func TestAccessTimestamp(t *testing.T) {
 	timeNow = fakeTime{}.Now
 
 	client := NewClient("123", "123")
-	_, err := client.CreateTransaction("", TxRequest{})
+	_, err := client.CreateTransaction(context.Background(), "", TxRequest{})
+	assert.Nil(t, err)
+}
+
+func TestJWTAuth(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	assert.Nil(t, err)
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(token, ".")
+		assert.Len(t, parts, 3)
+
+		var claims struct {
+			Sub string `json:"sub"`
+			URI string `json:"uri"`
+		}
+		decoded, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		assert.Nil(t, json.Unmarshal(decoded, &claims))
+		assert.Equal(t, "key", claims.Sub)
+		assert.Equal(t, "POST "+r.Host+"/accounts/transactions", claims.URI)
+
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		rs, ss := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		assert.True(t, ecdsa.Verify(&privateKey.PublicKey, digest[:], rs, ss))
+
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	coinbaseV2, _ = url.Parse(srv.URL)
+
+	auth, err := NewAuthenticator("key", string(privateKeyPEM))
+	assert.Nil(t, err)
+
+	client := NewClientWithAuth(auth)
+	_, err = client.CreateTransaction(context.Background(), "", TxRequest{})
+	assert.Nil(t, err)
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B, truncated to 6 digits
+	key := []byte("12345678901234567890")
+	assert.Equal(t, "287082", totp(key, 59/30))
+	assert.Equal(t, "081804", totp(key, 1111111109/30))
+	assert.Equal(t, "005924", totp(key, 1234567890/30))
+}
+
+func TestTwoFactor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("CB-2FA-TOKEN") != "123456" {
+			w.WriteHeader(http.StatusPaymentRequired)
+			w.Write([]byte(`{"errors":[{"id":"two_factor_required","message":"That action requires two-factor authentication"}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"id":"tx"}}`))
+	}))
+	defer srv.Close()
+
+	coinbaseV2, _ = url.Parse(srv.URL)
+
+	client := NewClient("123", "123")
+	_, err := client.CreateTransaction(context.Background(), "", TxRequest{})
+	assert.ErrorIs(t, err, ErrTwoFactorRequired)
+
+	tx, err := client.CreateTransaction(context.Background(), "", TxRequest{TwoFactorToken: "123456"})
 	assert.Nil(t, err)
+	assert.Equal(t, "tx", tx.ID)
 }
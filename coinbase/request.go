@@ -1,17 +1,78 @@
 package coinbase
 
+import (
+	"encoding/json"
+
+	"github.com/shopspring/decimal"
+)
+
 type TxRequest struct {
-	Type     string  `json:"type,omitempty"`
-	To       string  `json:"to,omitempty"`
-	Amount   float64 `json:"amount,omitempty,string"`
-	Currency string  `json:"currency,omitempty"`
+	Type     string          `json:"type,omitempty"`
+	To       string          `json:"to,omitempty"`
+	Amount   decimal.Decimal `json:"amount"`
+	Currency string          `json:"currency,omitempty"`
+	// Coinbase won't make a second send with the same idem, so a send that may or may not have gone through can be
+	// retried safely.
+	Idem        string          `json:"idem,omitempty"`
+	Network     string          `json:"network,omitempty"`
+	Description string          `json:"description,omitempty"`
+	TravelRule  *TravelRuleData `json:"travel_rule_data,omitempty"`
+	// Sent as the CB-2FA-TOKEN header on accounts that need one for sends.
+	TwoFactorToken string `json:"-"`
+}
+
+func (t TxRequest) MarshalJSON() ([]byte, error) {
+	// a distinct type so this method isn't called again
+	type txRequest TxRequest
+	var amount struct {
+		txRequest
+		Amount *decimal.Decimal `json:"amount,omitempty"`
+	}
+	amount.txRequest = txRequest(t)
+	if !t.Amount.IsZero() {
+		amount.Amount = &t.Amount
+	}
+	return json.Marshal(amount)
+}
+
+// TravelRuleData describes who a send is going to, which some jurisdictions require for sends to outside addresses.
+type TravelRuleData struct {
+	BeneficiaryName                 string              `json:"beneficiary_name,omitempty"`
+	BeneficiaryAddress              *BeneficiaryAddress `json:"beneficiary_address,omitempty"`
+	BeneficiaryWalletType           string              `json:"beneficiary_wallet_type,omitempty"`
+	BeneficiaryFinancialInstitution string              `json:"beneficiary_financial_institution,omitempty"`
+	IsSelf                          string              `json:"is_self,omitempty"`
+	TransferPurpose                 string              `json:"transfer_purpose,omitempty"`
+}
+
+type BeneficiaryAddress struct {
+	Address1   string `json:"address1,omitempty"`
+	Address2   string `json:"address2,omitempty"`
+	City       string `json:"city,omitempty"`
+	State      string `json:"state,omitempty"`
+	Country    string `json:"country,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
 }
 
 type DepositRequest struct {
-	Amount        float64 `json:"amount,omitempty,string"`
-	Currency      string  `json:"currency,omitempty"`
-	PaymentMethod string  `json:"payment_method,omitempty"`
-	Commit        bool    `json:"commit,omitempty"`
+	Amount        decimal.Decimal `json:"amount"`
+	Currency      string          `json:"currency,omitempty"`
+	PaymentMethod string          `json:"payment_method,omitempty"`
+	Commit        bool            `json:"commit,omitempty"`
+}
+
+func (d DepositRequest) MarshalJSON() ([]byte, error) {
+	// a distinct type so this method isn't called again
+	type depositRequest DepositRequest
+	var amount struct {
+		depositRequest
+		Amount *decimal.Decimal `json:"amount,omitempty"`
+	}
+	amount.depositRequest = depositRequest(d)
+	if !d.Amount.IsZero() {
+		amount.Amount = &d.Amount
+	}
+	return json.Marshal(amount)
 }
 
 type AdvancedOrderRequest struct {
@@ -19,9 +80,37 @@ type AdvancedOrderRequest struct {
 	ProductID          string `json:"product_id,omitempty"`
 	Side               string `json:"side,omitempty"`
 	OrderConfiguration struct {
-		MarketMarketIOC struct {
-			QuoteSize float64 `json:"quote_size,omitempty,string"`
-			BaseSize  float64 `json:"base_size,omitempty,string"`
-		} `json:"market_market_ioc,omitempty"`
+		MarketMarketIOC *MarketMarketIOC `json:"market_market_ioc,omitempty"`
+		LimitLimitGTC   *LimitLimitGTC   `json:"limit_limit_gtc,omitempty"`
 	} `json:"order_configuration,omitempty"`
 }
+
+// MarketMarketIOC takes either a quote or a base size, whichever is left zero isn't sent.
+type MarketMarketIOC struct {
+	QuoteSize decimal.Decimal `json:"quote_size"`
+	BaseSize  decimal.Decimal `json:"base_size"`
+}
+
+func (m MarketMarketIOC) MarshalJSON() ([]byte, error) {
+	var sizes struct {
+		QuoteSize *decimal.Decimal `json:"quote_size,omitempty"`
+		BaseSize  *decimal.Decimal `json:"base_size,omitempty"`
+	}
+	if !m.QuoteSize.IsZero() {
+		sizes.QuoteSize = &m.QuoteSize
+	}
+	if !m.BaseSize.IsZero() {
+		sizes.BaseSize = &m.BaseSize
+	}
+	return json.Marshal(sizes)
+}
+
+type LimitLimitGTC struct {
+	BaseSize   decimal.Decimal `json:"base_size"`
+	LimitPrice decimal.Decimal `json:"limit_price"`
+	PostOnly   bool            `json:"post_only,omitempty"`
+}
+
+type CancelOrdersRequest struct {
+	OrderIDs []string `json:"order_ids"`
+}
@@ -1,6 +1,10 @@
 package coinbase
 
-import "time"
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type AddressesResponse []AddressResponse
 
@@ -16,31 +20,31 @@ type AddressResponse struct {
 }
 
 type ProductResponse struct {
-	ProductID                 string  `json:"product_id,omitempty"`
-	Price                     float64 `json:"price,omitempty,string"`
-	PricePercentageChange24H  string  `json:"price_percentage_change_24h,omitempty"`
-	Volume24H                 string  `json:"volume_24h,omitempty"`
-	VolumePercentageChange24H string  `json:"volume_percentage_change_24h,omitempty"`
-	BaseIncrement             float64 `json:"base_increment,omitempty,string"`
-	QuoteIncrement            float64 `json:"quote_increment,omitempty,string"`
-	QuoteMinSize              float64 `json:"quote_min_size,omitempty,string"`
-	QuoteMaxSize              float64 `json:"quote_max_size,omitempty,string"`
-	BaseMinSize               float64 `json:"base_min_size,omitempty,string"`
-	BaseMaxSize               float64 `json:"base_max_size,omitempty,string"`
-	BaseName                  string  `json:"base_name,omitempty"`
-	QuoteName                 string  `json:"quote_name,omitempty"`
-	Watched                   bool    `json:"watched,omitempty"`
-	IsDisabled                bool    `json:"is_disabled,omitempty"`
-	New                       bool    `json:"new,omitempty"`
-	Status                    string  `json:"status,omitempty"`
-	CancelOnly                bool    `json:"cancel_only,omitempty"`
-	LimitOnly                 bool    `json:"limit_only,omitempty"`
-	PostOnly                  bool    `json:"post_only,omitempty"`
-	TradingDisabled           bool    `json:"trading_disabled,omitempty"`
-	AuctionMode               bool    `json:"auction_mode,omitempty"`
-	ProductType               string  `json:"product_type,omitempty"`
-	QuoteCurrencyID           string  `json:"quote_currency_id,omitempty"`
-	BaseCurrencyID            string  `json:"base_currency_id,omitempty"`
+	ProductID                 string          `json:"product_id,omitempty"`
+	Price                     decimal.Decimal `json:"price"`
+	PricePercentageChange24H  string          `json:"price_percentage_change_24h,omitempty"`
+	Volume24H                 string          `json:"volume_24h,omitempty"`
+	VolumePercentageChange24H string          `json:"volume_percentage_change_24h,omitempty"`
+	BaseIncrement             decimal.Decimal `json:"base_increment"`
+	QuoteIncrement            decimal.Decimal `json:"quote_increment"`
+	QuoteMinSize              decimal.Decimal `json:"quote_min_size"`
+	QuoteMaxSize              decimal.Decimal `json:"quote_max_size"`
+	BaseMinSize               decimal.Decimal `json:"base_min_size"`
+	BaseMaxSize               decimal.Decimal `json:"base_max_size"`
+	BaseName                  string          `json:"base_name,omitempty"`
+	QuoteName                 string          `json:"quote_name,omitempty"`
+	Watched                   bool            `json:"watched,omitempty"`
+	IsDisabled                bool            `json:"is_disabled,omitempty"`
+	New                       bool            `json:"new,omitempty"`
+	Status                    string          `json:"status,omitempty"`
+	CancelOnly                bool            `json:"cancel_only,omitempty"`
+	LimitOnly                 bool            `json:"limit_only,omitempty"`
+	PostOnly                  bool            `json:"post_only,omitempty"`
+	TradingDisabled           bool            `json:"trading_disabled,omitempty"`
+	AuctionMode               bool            `json:"auction_mode,omitempty"`
+	ProductType               string          `json:"product_type,omitempty"`
+	QuoteCurrencyID           string          `json:"quote_currency_id,omitempty"`
+	BaseCurrencyID            string          `json:"base_currency_id,omitempty"`
 }
 
 type AccountsResponse []AccountResponse
@@ -62,8 +66,8 @@ type AccountResponse struct {
 		Slug         string `json:"slug"`
 	} `json:"currency"`
 	Balance struct {
-		Amount   float64 `json:"amount,string"`
-		Currency string  `json:"currency"`
+		Amount   decimal.Decimal `json:"amount"`
+		Currency string          `json:"currency"`
 	} `json:"balance"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
@@ -73,6 +77,8 @@ type AccountResponse struct {
 	AllowWithdrawals bool      `json:"allow_withdrawals"`
 }
 
+type TransactionsResponse []TxResponse
+
 type TxResponse struct {
 	ID     string `json:"id"`
 	Type   string `json:"type"`
@@ -93,6 +99,7 @@ type TxResponse struct {
 	To           struct {
 		Resource string `json:"resource"`
 		Email    string `json:"email"`
+		Address  string `json:"address"`
 	} `json:"to"`
 	Details struct {
 		Title    string `json:"title"`
@@ -183,3 +190,88 @@ type AdvancedOrderResponse struct {
 		} `json:"market_market_ioc"`
 	} `json:"order_configuration"`
 }
+
+type TransactionSummaryResponse struct {
+	TotalVolume decimal.Decimal `json:"total_volume"`
+	TotalFees   decimal.Decimal `json:"total_fees"`
+	FeeTier     struct {
+		PricingTier  string          `json:"pricing_tier"`
+		UsdFrom      string          `json:"usd_from"`
+		UsdTo        string          `json:"usd_to"`
+		TakerFeeRate decimal.Decimal `json:"taker_fee_rate"`
+		MakerFeeRate decimal.Decimal `json:"maker_fee_rate"`
+	} `json:"fee_tier"`
+}
+
+type BestBidAskResponse struct {
+	Pricebooks []struct {
+		ProductID string `json:"product_id"`
+		Bids      []struct {
+			Price decimal.Decimal `json:"price"`
+			Size  decimal.Decimal `json:"size"`
+		} `json:"bids"`
+		Asks []struct {
+			Price decimal.Decimal `json:"price"`
+			Size  decimal.Decimal `json:"size"`
+		} `json:"asks"`
+		Time time.Time `json:"time"`
+	} `json:"pricebooks"`
+}
+
+type OrderResponse struct {
+	Order struct {
+		OrderID              string          `json:"order_id"`
+		ProductID            string          `json:"product_id"`
+		UserID               string          `json:"user_id"`
+		Side                 string          `json:"side"`
+		ClientOrderID        string          `json:"client_order_id"`
+		Status               string          `json:"status"`
+		TimeInForce          string          `json:"time_in_force"`
+		CreatedTime          time.Time       `json:"created_time"`
+		CompletionPercentage decimal.Decimal `json:"completion_percentage"`
+		FilledSize           decimal.Decimal `json:"filled_size"`
+		AverageFilledPrice   decimal.Decimal `json:"average_filled_price"`
+		NumberOfFills        int             `json:"number_of_fills,string"`
+		FilledValue          decimal.Decimal `json:"filled_value"`
+		PendingCancel        bool            `json:"pending_cancel"`
+		SizeInQuote          bool            `json:"size_in_quote"`
+		TotalFees            decimal.Decimal `json:"total_fees"`
+		TotalValueAfterFees  decimal.Decimal `json:"total_value_after_fees"`
+		OrderType            string          `json:"order_type"`
+		RejectReason         string          `json:"reject_reason"`
+		Settled              bool            `json:"settled"`
+		ProductType          string          `json:"product_type"`
+		RejectMessage        string          `json:"reject_message"`
+		CancelMessage        string          `json:"cancel_message"`
+	} `json:"order"`
+}
+
+type CancelOrdersResponse struct {
+	Results []struct {
+		Success       bool   `json:"success"`
+		FailureReason string `json:"failure_reason"`
+		OrderID       string `json:"order_id"`
+	} `json:"results"`
+}
+
+type FillsResponse struct {
+	Fills  []Fill `json:"fills"`
+	Cursor string `json:"cursor"`
+}
+
+type Fill struct {
+	EntryID            string          `json:"entry_id"`
+	TradeID            string          `json:"trade_id"`
+	OrderID            string          `json:"order_id"`
+	TradeTime          time.Time       `json:"trade_time"`
+	TradeType          string          `json:"trade_type"`
+	Price              decimal.Decimal `json:"price"`
+	Size               decimal.Decimal `json:"size"`
+	Commission         decimal.Decimal `json:"commission"`
+	ProductID          string          `json:"product_id"`
+	SequenceTimestamp  time.Time       `json:"sequence_timestamp"`
+	LiquidityIndicator string          `json:"liquidity_indicator"`
+	SizeInQuote        bool            `json:"size_in_quote"`
+	UserID             string          `json:"user_id"`
+	Side               string          `json:"side"`
+}
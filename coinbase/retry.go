@@ -0,0 +1,57 @@
+package coinbase
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cedws/fiat2xmr/internal/retry"
+)
+
+// Overridden in tests.
+var retryPolicy = func() retry.Policy {
+	policy := retry.Default()
+	policy.After = retryAfter
+	return policy
+}()
+
+// idempotent is implemented by request bodies carrying a key Coinbase uses to deduplicate them, so sending one again
+// can't repeat its effect.
+type idempotent interface {
+	idempotencyKey() string
+}
+
+func (r AdvancedOrderRequest) idempotencyKey() string {
+	return r.ClientOrderID
+}
+
+func (r TxRequest) idempotencyKey() string {
+	return r.Idem
+}
+
+// canRetry reports whether a request may be sent more than once. Anything that isn't a GET could place a second order
+// or make a second send, unless it carries an idempotency key.
+func canRetry(method string, body any) bool {
+	if method == http.MethodGet {
+		return true
+	}
+	if body, ok := body.(idempotent); ok {
+		return body.idempotencyKey() != ""
+	}
+	return false
+}
+
+// retryAfter reads the delay from a Retry-After header, or failing that when the Coinbase rate limit resets.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	if delay, ok := retry.RetryAfter(header, now); ok {
+		return delay, true
+	}
+
+	if header.Get("CB-RATELIMIT-REMAINING") == "0" {
+		if reset, err := strconv.ParseInt(header.Get("CB-RATELIMIT-RESET"), 10, 64); err == nil {
+			return retry.NonNegative(time.Unix(reset, 0).Sub(now)), true
+		}
+	}
+
+	return 0, false
+}
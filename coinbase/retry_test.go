@@ -0,0 +1,103 @@
+package coinbase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingServer fails the first failures requests with status, then succeeds. It returns a pointer to the number of
+// requests made.
+func failingServer(t *testing.T, failures, status int) *int {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			w.Write([]byte("{}"))
+			return
+		}
+		w.Write([]byte(`{"data":{},"success":true}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	oldV2, oldV3, oldDelay := coinbaseV2, coinbaseV3, retryPolicy.BaseDelay
+	coinbaseV2, _ = url.Parse(srv.URL)
+	coinbaseV3, _ = url.Parse(srv.URL)
+	retryPolicy.BaseDelay = time.Millisecond
+	t.Cleanup(func() {
+		coinbaseV2, coinbaseV3 = oldV2, oldV3
+		retryPolicy.BaseDelay = oldDelay
+	})
+	return &calls
+}
+
+func TestRetryGet(t *testing.T) {
+	calls := failingServer(t, 2, http.StatusServiceUnavailable)
+
+	client := NewClient("123", "123")
+	_, err := client.GetAccountByCode(context.Background(), "LTC")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, *calls)
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	calls := failingServer(t, retryPolicy.MaxAttempts+1, http.StatusTooManyRequests)
+
+	client := NewClient("123", "123")
+	_, err := client.GetAccountByCode(context.Background(), "LTC")
+	assert.NotNil(t, err)
+	assert.Equal(t, retryPolicy.MaxAttempts, *calls)
+}
+
+func TestNoRetryPost(t *testing.T) {
+	calls := failingServer(t, 1, http.StatusServiceUnavailable)
+
+	client := NewClient("123", "123")
+	_, err := client.CreateTransaction(context.Background(), "", TxRequest{})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestRetryIdempotentPost(t *testing.T) {
+	calls := failingServer(t, 1, http.StatusBadGateway)
+
+	client := NewClient("123", "123")
+	_, err := client.CreateAdvancedOrder(context.Background(), AdvancedOrderRequest{ClientOrderID: "order"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, *calls)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	delay, ok := retryAfter(http.Header{"Retry-After": {"5"}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, delay)
+
+	delay, ok = retryAfter(http.Header{"Retry-After": {now.Add(time.Minute).UTC().Format(http.TimeFormat)}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, delay)
+
+	delay, ok = retryAfter(http.Header{"Cb-Ratelimit-Remaining": {"0"}, "Cb-Ratelimit-Reset": {"1010"}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, delay)
+
+	_, ok = retryAfter(http.Header{"Cb-Ratelimit-Remaining": {"3"}, "Cb-Ratelimit-Reset": {"1010"}}, now)
+	assert.False(t, ok)
+}
+
+func TestRetryIdempotentSend(t *testing.T) {
+	calls := failingServer(t, 1, http.StatusServiceUnavailable)
+
+	client := NewClient("123", "123")
+	_, err := client.CreateTransaction(context.Background(), "", TxRequest{Idem: "send"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, *calls)
+}
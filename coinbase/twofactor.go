@@ -0,0 +1,38 @@
+package coinbase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// TwoFactorSource supplies a 2FA token when a send needs one.
+type TwoFactorSource func(ctx context.Context) (string, error)
+
+// NewTOTPSource returns a source generating RFC 6238 tokens from the base32 secret shown when setting up an
+// authenticator app.
+func NewTOTPSource(secret string) (TwoFactorSource, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	return func(ctx context.Context) (string, error) {
+		return totp(key, timeNow().Unix()/30), nil
+	}, nil
+}
+
+func totp(key []byte, counter int64) string {
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1_000_000)
+}
@@ -0,0 +1,60 @@
+package fiat2xmr
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+func (o Opts) checkAmounts() error {
+	if o.Reserve.IsNegative() {
+		return fmt.Errorf("reserve %v is negative", o.Reserve)
+	}
+	if o.ShiftAmount.IsNegative() {
+		return fmt.Errorf("shift amount %v is negative", o.ShiftAmount)
+	}
+	if o.ShiftAmount.IsPositive() && o.ShiftBought {
+		return fmt.Errorf("an exact shift amount can't be used with only shifting what was bought")
+	}
+	return nil
+}
+
+// shiftableAmount works out how much of balance to shift, given bought was bought by this run. The reserve is left
+// alone and every send's network fee has to fit in what remains, since Coinbase takes it on top of the amount sent.
+// Sends are split at max, so the number of fees depends on the amount. The result has no more than places decimal
+// places.
+func shiftableAmount(balance, bought, fee, max decimal.Decimal, places int32, opts Opts) (decimal.Decimal, error) {
+	available := balance.Sub(opts.Reserve)
+
+	if opts.ShiftAmount.IsPositive() {
+		amount := opts.ShiftAmount.RoundFloor(places)
+		if needed := amount.Add(fee.Mul(sendCount(amount, max))); needed.GreaterThan(available) {
+			return decimal.Zero, fmt.Errorf("balance %v can't cover %v plus network fees and reserve %v", balance, amount, opts.Reserve)
+		}
+		return amount, nil
+	}
+
+	amount := available
+	if opts.ShiftBought {
+		if !bought.IsPositive() {
+			return decimal.Zero, fmt.Errorf("nothing was bought to shift")
+		}
+		// the reserve may have eaten into what was bought
+		amount = decimal.Min(bought, available)
+	}
+
+	// fewer sends may be needed once the fees are taken off, which just leaves a little over
+	amount = amount.Sub(fee.Mul(sendCount(amount, max))).RoundFloor(places)
+	if !amount.IsPositive() {
+		return decimal.Zero, fmt.Errorf("balance %v leaves nothing to shift after network fees and reserve %v", balance, opts.Reserve)
+	}
+	return amount, nil
+}
+
+// sendCount is the number of sends splitAmount will divide amount into.
+func sendCount(amount, max decimal.Decimal) decimal.Decimal {
+	if !max.IsPositive() || amount.LessThanOrEqual(max) {
+		return decimal.NewFromInt(1)
+	}
+	return amount.Div(max).Ceil()
+}
@@ -0,0 +1,50 @@
+package fiat2xmr
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShiftableAmount(t *testing.T) {
+	d := decimal.RequireFromString
+	fee := d("0.001")
+
+	// the whole balance less one fee
+	amount, err := shiftableAmount(d("5"), d("2"), fee, d("10"), 8, Opts{})
+	assert.Nil(t, err)
+	assert.Equal(t, "4.999", amount.String())
+
+	// a fee for each of the three sends
+	amount, err = shiftableAmount(d("25"), decimal.Zero, fee, d("10"), 8, Opts{})
+	assert.Nil(t, err)
+	assert.Equal(t, "24.997", amount.String())
+
+	amount, err = shiftableAmount(d("5"), d("2"), fee, d("10"), 8, Opts{Reserve: d("1")})
+	assert.Nil(t, err)
+	assert.Equal(t, "3.999", amount.String())
+
+	amount, err = shiftableAmount(d("5"), d("2"), fee, d("10"), 8, Opts{ShiftBought: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "1.999", amount.String())
+
+	// the reserve comes first
+	amount, err = shiftableAmount(d("5"), d("2"), fee, d("10"), 8, Opts{ShiftBought: true, Reserve: d("4")})
+	assert.Nil(t, err)
+	assert.Equal(t, "0.999", amount.String())
+
+	_, err = shiftableAmount(d("5"), decimal.Zero, fee, d("10"), 8, Opts{ShiftBought: true})
+	assert.NotNil(t, err)
+
+	amount, err = shiftableAmount(d("5"), d("2"), fee, d("10"), 8, Opts{ShiftAmount: d("3")})
+	assert.Nil(t, err)
+	assert.Equal(t, "3", amount.String())
+
+	// no room for the fee on top
+	_, err = shiftableAmount(d("5"), d("2"), fee, d("10"), 8, Opts{ShiftAmount: d("5")})
+	assert.NotNil(t, err)
+
+	_, err = shiftableAmount(d("1"), d("2"), fee, d("10"), 8, Opts{Reserve: d("1")})
+	assert.NotNil(t, err)
+}
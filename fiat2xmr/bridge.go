@@ -0,0 +1,159 @@
+package fiat2xmr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/cedws/fiat2xmr/sideshift"
+	"github.com/shopspring/decimal"
+)
+
+type bridgeEstimate struct {
+	Base          string
+	Price         decimal.Decimal
+	TradingFee    decimal.Decimal
+	Bought        decimal.Decimal
+	SendFee       decimal.Decimal
+	DepositAmount decimal.Decimal
+	SettleAmount  decimal.Decimal
+}
+
+// selectBridge works out how much of the quote currency the current fiat balance would buy through each candidate
+// bridge coin and returns the currencies for the route that gives the most.
+func (c *Converter) selectBridge(ctx context.Context, currencies Currencies, opts Opts) (Currencies, error) {
+	fiatBalance, err := c.getBalance(ctx, currencies.Fiat)
+	if err != nil {
+		return currencies, err
+	}
+	log.Infof("fiat balance is %v", fiatBalance)
+
+	summary, err := c.cbClient.GetTransactionSummary(ctx)
+	if err != nil {
+		return currencies, err
+	}
+	feeRate := summary.FeeTier.TakerFeeRate
+	log.Infof("taker fee rate is %v", feeRate)
+
+	var best *bridgeEstimate
+	for _, candidate := range opts.BridgeCandidates {
+		candidate = strings.ToUpper(candidate)
+
+		route := currencies
+		route.Base = candidate
+		estimate, err := c.estimateBridge(ctx, route, fiatBalance, feeRate, opts)
+		if err != nil {
+			log.Warnf("skipping bridge %v: %v", candidate, err)
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"base":     estimate.Base,
+			"price":    estimate.Price,
+			"fee":      estimate.TradingFee,
+			"bought":   estimate.Bought,
+			"send_fee": estimate.SendFee,
+			"deposit":  estimate.DepositAmount,
+			"settle":   estimate.SettleAmount,
+		}).Info("bridge estimate")
+
+		if best == nil || estimate.SettleAmount.GreaterThan(best.SettleAmount) {
+			best = estimate
+		}
+	}
+
+	if best == nil {
+		return currencies, fmt.Errorf("none of the bridge candidates %v are usable", opts.BridgeCandidates)
+	}
+	log.Infof("selected %v as bridge, expecting %v %v", best.Base, best.SettleAmount, currencies.Quote)
+
+	currencies.Base = best.Base
+	return currencies, nil
+}
+
+// estimateBridge works out how much of the quote currency the fiat balance would buy on the route through
+// currencies.Base, predicting the order the same way the pre-flight checks do. Deposits above the pair maximum are
+// split across several shifts like a real run, so one full-sized leg is quoted and its rate applied to what the fiat
+// buys less network fees.
+func (c *Converter) estimateBridge(ctx context.Context, currencies Currencies, fiatBalance, feeRate decimal.Decimal, opts Opts) (*bridgeEstimate, error) {
+	base := currencies.Base
+	productID := fmt.Sprintf("%v-%v", base, currencies.Fiat)
+
+	product, err := c.cbClient.GetProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.TradingDisabled || product.IsDisabled || product.CancelOnly {
+		return nil, fmt.Errorf("trading for product %v is disabled", productID)
+	}
+	if !product.Price.IsPositive() {
+		return nil, fmt.Errorf("product %v has no price", productID)
+	}
+
+	orderVolumeFiat := toIncrement(decimal.Min(fiatBalance, product.QuoteMaxSize), product.QuoteIncrement, decimal.Decimal.Floor)
+	if orderVolumeFiat.LessThan(product.QuoteMinSize) {
+		return nil, fmt.Errorf("fiat balance below minimum order size %v", product.QuoteMinSize)
+	}
+
+	book, err := c.cbClient.GetBestBidAsk(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	bought := estimateBought(orderVolumeFiat, feeRate, product.Price, askLevels(book))
+	tradingFee := orderVolumeFiat.Sub(orderVolumeFiat.Div(feeRate.Add(decimal.NewFromInt(1))))
+
+	account, err := c.cbClient.GetAccountByCode(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := c.ssClient.GetPair(ctx, currencies.pairBase(), currencies.pairQuote())
+	if err != nil {
+		return nil, err
+	}
+
+	sendFee := opts.sendFee(base)
+	places := int32(account.Currency.Exponent)
+	// whatever is already held is shifted too, so it counts towards the pair limits but not towards the ranking
+	shiftable, err := shiftableAmount(account.Balance.Amount.Add(bought), bought, sendFee, pair.Max, places, opts)
+	if err != nil {
+		return nil, err
+	}
+	if shiftable.LessThan(pair.Min) {
+		return nil, fmt.Errorf("estimated %v %v to shift is below shift minimum %v", shiftable, base, pair.Min)
+	}
+	amounts, err := splitAmount(shiftable, pair.Min, pair.Max, places)
+	if err != nil {
+		return nil, err
+	}
+	depositAmount, err := shiftableAmount(bought, bought, sendFee, pair.Max, places, Opts{})
+	if err != nil {
+		return nil, err
+	}
+
+	// the first leg is the largest, the rest only differ by rounding
+	quoteResp, err := c.ssClient.CreateQuote(ctx, sideshift.QuoteRequest{
+		DepositCoin:   base,
+		SettleCoin:    currencies.Quote,
+		SettleNetwork: currencies.QuoteNetwork,
+		DepositAmount: amounts[0],
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !quoteResp.DepositAmount.IsPositive() {
+		return nil, fmt.Errorf("quote %v has no deposit amount", quoteResp.ID)
+	}
+	settleAmount := quoteResp.SettleAmount.Mul(depositAmount).Div(quoteResp.DepositAmount)
+
+	return &bridgeEstimate{
+		Base:          base,
+		Price:         product.Price,
+		TradingFee:    tradingFee,
+		Bought:        bought,
+		SendFee:       sendFee,
+		DepositAmount: depositAmount,
+		SettleAmount:  settleAmount,
+	}, nil
+}
@@ -0,0 +1,36 @@
+package fiat2xmr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectBridgeIgnoresHeldBalance(t *testing.T) {
+	cb := newStubAPI(map[string][]string{
+		"GET /v3/brokerage/transaction_summary": {`{"fee_tier":{"taker_fee_rate":"0"}}`},
+		"GET /v3/brokerage/best_bid_ask":        {`{"pricebooks":[]}`},
+		"GET /v3/brokerage/products/LTC-GBP":    {`{"product_id":"LTC-GBP","price":"100","quote_min_size":"1","quote_max_size":"1000"}`},
+		"GET /v3/brokerage/products/BTC-GBP":    {`{"product_id":"BTC-GBP","price":"10000","quote_min_size":"1","quote_max_size":"1000"}`},
+		"GET /v2/accounts/GBP":                  {`{"data":{"id":"gbp","balance":{"amount":"100","currency":"GBP"}}}`},
+		// plenty of LTC is already held, which would make LTC look best if it were counted
+		"GET /v2/accounts/LTC": {`{"data":{"id":"ltc","currency":{"exponent":8},"balance":{"amount":"10","currency":"LTC"}}}`},
+		"GET /v2/accounts/BTC": {`{"data":{"id":"btc","currency":{"exponent":8},"balance":{"amount":"0","currency":"BTC"}}}`},
+	})
+	ss := newStubAPI(map[string][]string{
+		"GET /api/v2/pair/LTC/XMR": {`{"min":"0.1","max":"100","rate":"0.5","depositCoin":"LTC","settleCoin":"XMR"}`},
+		"GET /api/v2/pair/BTC/XMR": {`{"min":"0.001","max":"10","rate":"200","depositCoin":"BTC","settleCoin":"XMR"}`},
+		// candidates are quoted in order
+		"POST /api/v2/quotes": {
+			`{"id":"ltc","depositAmount":"10","settleAmount":"5","rate":"0.5"}`,
+			`{"id":"btc","depositAmount":"0.01","settleAmount":"2","rate":"200"}`,
+		},
+	})
+	cnv := stubConverter(t, cb, ss)
+
+	// a pound buys 0.01 LTC or 0.0001 BTC, and BTC gets 4 times as much XMR for it
+	currencies, err := cnv.selectBridge(context.Background(), Currencies{}.withDefaults(), Opts{BridgeCandidates: []string{"ltc", "btc"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "BTC", currencies.Base)
+}
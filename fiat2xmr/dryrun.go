@@ -0,0 +1,160 @@
+package fiat2xmr
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/apex/log"
+	"github.com/cedws/fiat2xmr/coinbase"
+	"github.com/cedws/fiat2xmr/sideshift"
+	"github.com/shopspring/decimal"
+)
+
+// dryRun goes through the same pre-flight checks as a real conversion and logs the order, shift and transaction that
+// would be created. Nothing that moves money or creates addresses is called.
+func (c *Converter) dryRun(ctx context.Context, currencies Currencies, opts Opts) error {
+	plan, err := c.planOrder(ctx, currencies, opts)
+	if err != nil {
+		return err
+	}
+
+	baseAmount := plan.baseBalance
+	switch {
+	case plan.order != nil && opts.Maker:
+		summary, err := c.cbClient.GetTransactionSummary(ctx)
+		if err != nil {
+			return err
+		}
+
+		order, err := c.planLimitOrder(ctx, plan.product, plan.fiatBalance, summary.FeeTier.MakerFeeRate)
+		if err != nil {
+			return err
+		}
+		if order == nil {
+			log.Info("fiat balance too low to place a maker order, would shift existing base balance")
+			break
+		}
+
+		limit := order.OrderConfiguration.LimitLimitGTC
+		tradingFee := limit.BaseSize.Mul(limit.LimitPrice).Mul(summary.FeeTier.MakerFeeRate)
+		baseAmount = baseAmount.Add(limit.BaseSize)
+
+		log.WithFields(log.Fields{
+			"price":       limit.LimitPrice,
+			"trading_fee": tradingFee,
+			"fee_rate":    summary.FeeTier.MakerFeeRate,
+		}).Infof("would create first maker order %s", describe(order))
+	case plan.order != nil:
+		summary, err := c.cbClient.GetTransactionSummary(ctx)
+		if err != nil {
+			return err
+		}
+
+		quoteSize := plan.order.OrderConfiguration.MarketMarketIOC.QuoteSize
+		tradingFee := quoteSize.Mul(summary.FeeTier.TakerFeeRate)
+		baseAmount = baseAmount.Add(plan.bought)
+
+		log.WithFields(log.Fields{
+			"price":       plan.product.Price,
+			"trading_fee": tradingFee,
+			"fee_rate":    summary.FeeTier.TakerFeeRate,
+		}).Infof("would create order %s", describe(plan.order))
+	default:
+		log.Info("fiat balance too low to place an order, would shift existing base balance")
+	}
+
+	refundAddress := "(new address)"
+	addresses, err := c.cbClient.GetAddresses(ctx, currencies.Base)
+	if err != nil {
+		return err
+	}
+	if len(*addresses) > 0 {
+		refundAddress = (*addresses)[0].Address
+	}
+
+	account, err := c.cbClient.GetAccountByCode(ctx, currencies.Base)
+	if err != nil {
+		return err
+	}
+
+	places := int32(account.Currency.Exponent)
+	bought := baseAmount.Sub(plan.baseBalance)
+	amount, err := shiftableAmount(baseAmount, bought, opts.sendFee(currencies.Base), plan.pair.Max, places, opts)
+	if err != nil {
+		return err
+	}
+	log.Infof("would shift %v %v", amount, currencies.Base)
+
+	amounts, err := splitAmount(amount, plan.pair.Min, plan.pair.Max, places)
+	if err != nil {
+		return err
+	}
+	if len(amounts) > 1 {
+		log.Infof("would split %v %v into %v shifts", amount, currencies.Base, len(amounts))
+	}
+
+	var settleAmount decimal.Decimal
+	for i, amount := range amounts {
+		logger := log.WithField("shift", i+1)
+
+		depositAmount := amount
+		depositNetwork := plan.pair.DepositNetwork
+		if opts.ShiftType == ShiftTypeVariable {
+			// there's no quote for variable shifts, the pair rate is the best guess until the deposit arrives
+			settleAmount = settleAmount.Add(amount.Mul(plan.pair.Rate))
+
+			shift := sideshift.VariableShiftRequest{
+				SettleAddress:  opts.Address,
+				RefundAddress:  refundAddress,
+				DepositCoin:    currencies.Base,
+				SettleCoin:     currencies.Quote,
+				DepositNetwork: currencies.BaseNetwork,
+				SettleNetwork:  currencies.QuoteNetwork,
+			}
+			logger.WithField("rate", plan.pair.Rate).Infof("would create variable shift %s", describe(shift))
+		} else {
+			quote, err := c.ssClient.CreateQuote(ctx, sideshift.QuoteRequest{
+				DepositCoin:    currencies.Base,
+				SettleCoin:     currencies.Quote,
+				DepositNetwork: currencies.BaseNetwork,
+				SettleNetwork:  currencies.QuoteNetwork,
+				DepositAmount:  amount,
+			})
+			if err != nil {
+				return err
+			}
+			settleAmount = settleAmount.Add(quote.SettleAmount)
+			depositAmount = quote.DepositAmount
+			depositNetwork = quote.DepositNetwork
+
+			shift := sideshift.FixedShiftRequest{
+				SettleAddress: opts.Address,
+				RefundAddress: refundAddress,
+				QuoteID:       quote.ID,
+			}
+			logger.WithField("rate", quote.Rate).Infof("would create shift %s", describe(shift))
+		}
+
+		tx := coinbase.TxRequest{
+			Type:        "send",
+			To:          "(shift deposit address)",
+			Amount:      depositAmount,
+			Currency:    currencies.Base,
+			Network:     depositNetwork,
+			Description: opts.SendDescription,
+			TravelRule:  opts.TravelRule,
+		}
+		logger.WithField("send_fee", opts.sendFee(currencies.Base)).Infof("would create transaction %s", describe(tx))
+	}
+
+	log.Infof("expecting %v %v", settleAmount, currencies.Quote)
+	return nil
+}
+
+func describe(v any) string {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	return string(encoded)
+}
@@ -0,0 +1,60 @@
+package fiat2xmr
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNothingToResume = errors.New("nothing to resume")
+
+// PreflightError is returned when a check fails before any money has moved.
+type PreflightError struct {
+	Err error
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("pre-flight: %v", e.Err)
+}
+
+func (e *PreflightError) Unwrap() error {
+	return e.Err
+}
+
+// OrderError is returned when buying the base currency on Coinbase fails.
+type OrderError struct {
+	Err error
+}
+
+func (e *OrderError) Error() string {
+	return fmt.Sprintf("order: %v", e.Err)
+}
+
+func (e *OrderError) Unwrap() error {
+	return e.Err
+}
+
+// ShiftError is returned when quoting, creating or waiting on the shift fails.
+type ShiftError struct {
+	Err error
+}
+
+func (e *ShiftError) Error() string {
+	return fmt.Sprintf("shift: %v", e.Err)
+}
+
+func (e *ShiftError) Unwrap() error {
+	return e.Err
+}
+
+// SendError is returned when sending the base currency to the shift deposit address fails.
+type SendError struct {
+	Err error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("send: %v", e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,46 @@
+package fiat2xmr
+
+import (
+	"github.com/cedws/fiat2xmr/coinbase"
+	"github.com/shopspring/decimal"
+)
+
+type priceLevel struct {
+	Price decimal.Decimal
+	Size  decimal.Decimal
+}
+
+func askLevels(book *coinbase.BestBidAskResponse) []priceLevel {
+	if len(book.Pricebooks) == 0 {
+		return nil
+	}
+
+	levels := make([]priceLevel, 0, len(book.Pricebooks[0].Asks))
+	for _, ask := range book.Pricebooks[0].Asks {
+		levels = append(levels, priceLevel{ask.Price, ask.Size})
+	}
+	return levels
+}
+
+// estimateBought predicts how much base currency a market buy spending fiat will get. Coinbase takes the trading fee
+// out of fiat, so less than all of it buys base currency. The asks are walked best first, with whatever they can't
+// fill assumed to fill at the last of them, or at price if there are none.
+func estimateBought(fiat, feeRate, price decimal.Decimal, asks []priceLevel) decimal.Decimal {
+	remaining := fiat.Div(feeRate.Add(decimal.NewFromInt(1)))
+
+	bought := decimal.Zero
+	for _, ask := range asks {
+		if !ask.Price.IsPositive() {
+			continue
+		}
+		spend := decimal.Min(remaining, ask.Price.Mul(ask.Size))
+		bought = bought.Add(spend.Div(ask.Price))
+		remaining = remaining.Sub(spend)
+		price = ask.Price
+	}
+
+	if remaining.IsPositive() && price.IsPositive() {
+		bought = bought.Add(remaining.Div(price))
+	}
+	return bought
+}
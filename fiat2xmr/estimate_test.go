@@ -0,0 +1,26 @@
+package fiat2xmr
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateBought(t *testing.T) {
+	d := decimal.RequireFromString
+
+	// fiat divided by price, not the other way round
+	assert.Equal(t, "2", estimateBought(d("100"), decimal.Zero, d("50"), nil).String())
+
+	// the fee comes out of the fiat spent
+	assert.Equal(t, "2", estimateBought(d("101"), d("0.01"), d("50"), nil).String())
+
+	// the first ask fills 1 for 50, the rest fills at the second ask
+	asks := []priceLevel{{d("50"), d("1")}, {d("100"), d("0.1")}}
+	assert.Equal(t, "1.5", estimateBought(d("100"), decimal.Zero, d("40"), asks).String())
+
+	// the ask is worse than the last traded price
+	asks = []priceLevel{{d("100"), d("10")}}
+	assert.Equal(t, "1", estimateBought(d("100"), decimal.Zero, d("50"), asks).String())
+}
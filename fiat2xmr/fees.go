@@ -0,0 +1,32 @@
+package fiat2xmr
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Coinbase doesn't expose a network fee estimate for sends, so these are rough figures in units of each coin. They can
+// be overridden with Opts.SendFees when the network is busy.
+var defaultSendFees = map[string]decimal.Decimal{
+	"BTC":  decimal.RequireFromString("0.0001"),
+	"BCH":  decimal.RequireFromString("0.0001"),
+	"LTC":  decimal.RequireFromString("0.0001"),
+	"DOGE": decimal.RequireFromString("1"),
+	"ETH":  decimal.RequireFromString("0.001"),
+	"USDC": decimal.RequireFromString("2"),
+	"USDT": decimal.RequireFromString("2"),
+	"XLM":  decimal.RequireFromString("0.00001"),
+	"SOL":  decimal.RequireFromString("0.00005"),
+}
+
+func (o Opts) sendFee(currency string) decimal.Decimal {
+	currency = strings.ToUpper(currency)
+
+	for code, fee := range o.SendFees {
+		if strings.EqualFold(code, currency) {
+			return fee
+		}
+	}
+	return defaultSendFees[currency]
+}
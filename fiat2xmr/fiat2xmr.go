@@ -1,178 +1,632 @@
 package fiat2xmr
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"math"
+	"io/fs"
+	"strings"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/cedws/fiat2xmr/coinbase"
+	"github.com/cedws/fiat2xmr/monero"
 	"github.com/cedws/fiat2xmr/sideshift"
+	"github.com/cedws/fiat2xmr/walletrpc"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 const (
-	fiatCurrency  = "GBP"
-	baseCurrency  = "LTC"
-	quoteCurrency = "XMR"
+	ShiftTypeFixed    = "fixed"
+	ShiftTypeVariable = "variable"
 )
 
+const (
+	DefaultFiatCurrency  = "GBP"
+	DefaultBaseCurrency  = "LTC"
+	DefaultQuoteCurrency = "XMR"
+)
+
+// Currencies describes the route of a conversion. Fiat is used to buy Base on Coinbase, which is then shifted to Quote.
+// The networks are only needed for coins on more than one chain, otherwise each coin's default is used.
+type Currencies struct {
+	Fiat         string `json:"fiat"`
+	Base         string `json:"base"`
+	Quote        string `json:"quote"`
+	BaseNetwork  string `json:"base_network,omitempty"`
+	QuoteNetwork string `json:"quote_network,omitempty"`
+}
+
 type Opts struct {
 	CoinbaseKey     string
 	CoinbaseSecret  string
 	SideShiftSecret string
 	Address         string
+	// Hex encoded payment ID to combine with a standard Monero address into an integrated address.
+	PaymentID string
+	// Hex encoded private view key of the wallet with primary address Address. If set, every conversion settles to a
+	// fresh subaddress of that wallet instead of to Address itself.
+	ViewKey string
+	// Account (major index) to derive subaddresses in.
+	SubaddressAccount uint32
+	// Where the next unused subaddress index is kept between runs.
+	SubaddressStatePath string
+	JournalPath         string
+	Currencies          Currencies
+	// If set, the base currency is chosen from these by whichever gives the most of the quote currency.
+	BridgeCandidates []string
+	// Estimated network fees for sends, keyed by currency code. Overrides the built-in estimates.
+	SendFees map[string]decimal.Decimal
+	// Base currency to leave on Coinbase. The rest of the balance is shifted, less network fees.
+	Reserve decimal.Decimal
+	// Shift exactly this much base currency instead of the balance. Network fees are paid on top.
+	ShiftAmount decimal.Decimal
+	// Only shift the base currency this run bought, leaving whatever was held beforehand.
+	ShiftBought bool
+	// Only run read-only calls and log what would be created.
+	DryRun bool
+	// Either fixed, where each shift is made from a quote, or variable, where the rate is set when the deposit arrives.
+	// Defaults to fixed.
+	ShiftType string
+	// Run the shifts concurrently when the balance has to be split across several of them.
+	ConcurrentShifts bool
+	// Buy with post-only limit orders to pay maker rather than taker fees.
+	Maker bool
+	// How long a maker order may rest before it is re-priced against the book. Defaults to one minute.
+	MakerRepriceInterval time.Duration
+	// How long to keep trying maker orders before buying the remainder at market. Defaults to 15 minutes.
+	MakerTimeout time.Duration
+	// Supplies a token for sends on Coinbase accounts with 2FA. Without one, such sends fail with
+	// coinbase.ErrTwoFactorRequired.
+	TwoFactor coinbase.TwoFactorSource
+	// Description and travel rule details attached to the sends to SideShift.
+	SendDescription string
+	TravelRule      *coinbase.TravelRuleData
+	// How often to check on a shift once it has been paid. Defaults to 10 seconds.
+	ShiftPollInterval time.Duration
+	// How long to wait for a paid shift to settle before giving up. Zero waits indefinitely.
+	ShiftTimeout time.Duration
+	// Called whenever the status of a shift changes, with the index of the leg it belongs to.
+	OnShiftStatus func(leg int, shift *sideshift.ShiftResponse)
+	// Confirmations the payout needs in the wallet before a shift is settled. Defaults to 10.
+	Confirmations uint64
 }
 
 type Converter struct {
 	ssClient *sideshift.Client
 	cbClient *coinbase.Client
+	// Optional, used to confirm XMR has arrived rather than trusting SideShift.
+	walletClient *walletrpc.Client
 }
 
 func NewConverter(ssClient *sideshift.Client, cbClient *coinbase.Client) *Converter {
-	return &Converter{ssClient, cbClient}
+	return &Converter{ssClient, cbClient, nil}
+}
+
+// NewConverterWithWallet returns a converter that only considers a shift settled once the payout has enough
+// confirmations in the wallet behind walletClient.
+func NewConverterWithWallet(ssClient *sideshift.Client, cbClient *coinbase.Client, walletClient *walletrpc.Client) *Converter {
+	return &Converter{ssClient, cbClient, walletClient}
+}
+
+// Result holds the records created by a conversion. Records created by an earlier run that was later resumed are
+// not fetched again, so any of them may be nil or missing.
+type Result struct {
+	Currencies Currencies
+	Address    string
+	// PaymentID is the hex encoded payment ID embedded in an integrated Monero address, if any.
+	PaymentID    string
+	Order        *coinbase.AdvancedOrderResponse
+	Fill         *OrderFill
+	Shifts       []*sideshift.ShiftResponse
+	Transactions []*coinbase.TxResponse
+	// SettleAmount is the total settled across all shifts.
+	SettleAmount decimal.Decimal
+}
+
+func (c *Converter) Convert(ctx context.Context, opts Opts) (*Result, error) {
+	var err error
+
+	currencies := opts.Currencies.withDefaults()
+	switch opts.ShiftType {
+	case "":
+		opts.ShiftType = ShiftTypeFixed
+	case ShiftTypeFixed, ShiftTypeVariable:
+	default:
+		return nil, &PreflightError{fmt.Errorf("unknown shift type %v", opts.ShiftType)}
+	}
+	if err := opts.checkAmounts(); err != nil {
+		return nil, &PreflightError{err}
+	}
+
+	var useSubaddress func() error
+	if opts.ViewKey != "" {
+		if currencies.Quote != "XMR" {
+			return nil, &PreflightError{fmt.Errorf("subaddresses can only be derived when settling in XMR")}
+		}
+		if opts.Address, useSubaddress, err = nextSubaddress(opts); err != nil {
+			return nil, &PreflightError{err}
+		}
+		log.Infof("settling to subaddress %v", opts.Address)
+	}
+	if opts.Address, err = settleAddress(opts.Address, opts.PaymentID, currencies.Quote); err != nil {
+		return nil, &PreflightError{err}
+	}
+	if id := paymentID(opts.Address); id != "" {
+		log.Infof("settling to integrated address %v with payment ID %v", opts.Address, id)
+	}
+	if len(opts.BridgeCandidates) > 0 {
+		if currencies.BaseNetwork != "" {
+			return nil, &PreflightError{fmt.Errorf("a base network can't be used with bridge candidates")}
+		}
+		// both are amounts of the base currency, which isn't known until a bridge is picked
+		if opts.Reserve.IsPositive() || opts.ShiftAmount.IsPositive() {
+			return nil, &PreflightError{fmt.Errorf("a reserve or shift amount can't be used with bridge candidates")}
+		}
+		if currencies, err = c.selectBridge(ctx, currencies, opts); err != nil {
+			return nil, &PreflightError{err}
+		}
+	}
+	if err := c.validateCurrencies(ctx, currencies); err != nil {
+		return nil, &PreflightError{err}
+	}
+
+	if opts.DryRun {
+		if err := c.dryRun(ctx, currencies, opts); err != nil {
+			return nil, &PreflightError{err}
+		}
+		return &Result{Currencies: currencies, Address: opts.Address, PaymentID: paymentID(opts.Address)}, nil
+	}
+
+	journal, err := OpenJournal(opts.JournalPath)
+	switch {
+	case err == nil && !journal.Done():
+		return nil, &PreflightError{fmt.Errorf("journal %v has an unfinished conversion, resume it first", opts.JournalPath)}
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return nil, &PreflightError{err}
+	}
+
+	if useSubaddress != nil {
+		// skipping an index if the journal can't be saved is harmless, wallets scan ahead for subaddresses
+		if err := useSubaddress(); err != nil {
+			return nil, &PreflightError{err}
+		}
+	}
+
+	journal = NewJournal(opts.JournalPath)
+	journal.Address = opts.Address
+	journal.Currencies = currencies
+	journal.ShiftType = opts.ShiftType
+	journal.Reserve = opts.Reserve
+	journal.ShiftAmount = opts.ShiftAmount
+	journal.ShiftBought = opts.ShiftBought
+	journal.SendFees = opts.SendFees
+	journal.Maker = opts.Maker
+	journal.MakerRepriceInterval = opts.MakerRepriceInterval
+	journal.MakerTimeout = opts.MakerTimeout
+	if err := journal.Save(); err != nil {
+		return nil, &PreflightError{err}
+	}
+
+	return c.run(ctx, journal, opts)
+}
+
+func (c *Converter) Resume(ctx context.Context, opts Opts) (*Result, error) {
+	journal, err := OpenJournal(opts.JournalPath)
+	if err != nil {
+		return nil, err
+	}
+	if journal.Done() {
+		return nil, fmt.Errorf("conversion in journal %v already settled: %w", opts.JournalPath, ErrNothingToResume)
+	}
+	// journals written before currencies were configurable won't have them
+	journal.Currencies = journal.Currencies.withDefaults()
+
+	log.Infof("resuming %v to %v conversion via %v from step %v", journal.Currencies.Fiat, journal.Currencies.Quote, journal.Currencies.Base, journal.Step)
+	return c.run(ctx, journal, opts)
 }
 
-func (c *Converter) Convert(opts Opts) {
-	if err := c.createOrder(baseCurrency); err != nil {
-		log.Fatalf("%v", err)
+func (c *Converter) run(ctx context.Context, journal *Journal, opts Opts) (*Result, error) {
+	// a resumed run carries on the way the original run meant to, whatever resume was given
+	opts = journal.restoreOpts(opts)
+
+	result := &Result{
+		Currencies: journal.Currencies,
+		Address:    journal.Address,
+		PaymentID:  paymentID(journal.Address),
+	}
+
+	for !journal.Done() {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		var err error
+
+		switch journal.Step {
+		case StepStarted:
+			var preflightErr *PreflightError
+			err = c.stepOrder(ctx, journal, opts, result)
+			switch {
+			case errors.As(err, &preflightErr) && len(journal.OrderIDs) == 0:
+				// nothing was bought, so a journal left behind would only stop the next run from starting
+				if err := journal.Remove(); err != nil {
+					log.Warnf("could not remove journal %v: %v", journal.path, err)
+				}
+			case err != nil && !errors.As(err, &preflightErr):
+				err = &OrderError{err}
+			}
+		case StepOrdered:
+			if err = c.stepSplit(ctx, journal, opts); err != nil {
+				err = &ShiftError{err}
+			}
+		case StepSplit:
+			if err = c.runShifts(ctx, journal, opts, result); err == nil {
+				err = journal.Advance(StepSettled)
+			}
+		default:
+			err = fmt.Errorf("unknown journal step %v", journal.Step)
+		}
+
+		if err != nil {
+			return result, err
+		}
 	}
 
-	baseAccount, err := c.cbClient.GetAccountByCode(baseCurrency)
+	return result, nil
+}
+
+func (c *Converter) stepOrder(ctx context.Context, journal *Journal, opts Opts, result *Result) error {
+	// orders placed before we were interrupted still count towards what was bought
+	total := &OrderFill{}
+	if n := len(journal.OrderIDs); n > 0 {
+		// a maker order may still be resting on the book
+		if err := c.cancelOrder(ctx, journal.OrderIDs[n-1]); err != nil {
+			return err
+		}
+		for _, orderID := range journal.OrderIDs {
+			fill, err := c.fillOrder(ctx, orderID)
+			if err != nil {
+				return err
+			}
+			total.add(fill)
+		}
+		log.Infof("earlier orders bought %v %v", total.FilledSize, journal.Currencies.Base)
+	}
+
+	order, fill, err := c.createOrder(ctx, journal, opts)
 	if err != nil {
-		log.Fatalf("%v", err)
+		return err
+	}
+	if fill != nil {
+		total.add(fill)
 	}
 
-	quote, err := c.ssClient.CreateQuote(sideshift.QuoteRequest{
-		DepositCoin:   baseCurrency,
-		SettleCoin:    quoteCurrency,
-		DepositAmount: baseAccount.Balance.Amount,
+	result.Order = order
+	if len(total.OrderIDs) > 0 {
+		result.Fill = total
+	}
+
+	return journal.Update(func() {
+		journal.FilledSize = total.FilledSize
+		journal.Step = StepOrdered
 	})
+}
+
+// stepSplit divides the amount to shift into legs that each fit within the pair limits.
+func (c *Converter) stepSplit(ctx context.Context, journal *Journal, opts Opts) error {
+	account, err := c.cbClient.GetAccountByCode(ctx, journal.Currencies.Base)
 	if err != nil {
-		log.Fatalf("%v", err)
+		return err
 	}
-	log.Infof("shift quote price is %v, expires at %v", quote.Rate, quote.ExpiresAt)
+	baseBalance := account.Balance.Amount
+	log.Infof("base balance is %v", baseBalance)
 
-	refundAddress, err := c.getRefundAddress(baseCurrency)
+	pair, err := c.ssClient.GetPair(ctx, journal.Currencies.pairBase(), journal.Currencies.pairQuote())
 	if err != nil {
-		log.Fatalf("%v", err)
+		return err
 	}
-	log.Infof("using %v as base refund address", refundAddress)
 
-	log.Infof("creating fixed shift")
-	shift, err := c.ssClient.CreateFixedShift(sideshift.FixedShiftRequest{
-		SettleAddress: opts.Address,
-		RefundAddress: refundAddress,
-		QuoteID:       quote.ID,
-	})
+	places := int32(account.Currency.Exponent)
+	amount, err := shiftableAmount(baseBalance, journal.FilledSize, opts.sendFee(journal.Currencies.Base), pair.Max, places, opts)
+	if err != nil {
+		return err
+	}
+	log.Infof("shifting %v %v", amount, journal.Currencies.Base)
+
+	// parts can't be more precise than coinbase will send
+	amounts, err := splitAmount(amount, pair.Min, pair.Max, places)
 	if err != nil {
-		log.Fatalf("%v", err)
+		return err
+	}
+	if len(amounts) > 1 {
+		log.Infof("%v %v is above shift maximum %v, splitting into %v shifts", amount, journal.Currencies.Base, pair.Max, len(amounts))
 	}
 
-	log.Infof("sending %v %v to shift address %v", baseAccount.Balance.Amount, baseCurrency, shift.DepositAddress)
-	_, err = c.cbClient.CreateTransaction(baseAccount.ID, coinbase.TxRequest{
-		Type:     "send",
-		To:       shift.DepositAddress,
-		Amount:   baseAccount.Balance.Amount,
-		Currency: baseCurrency,
+	return journal.Update(func() {
+		journal.Shifts = make([]*ShiftLeg, 0, len(amounts))
+		for _, amount := range amounts {
+			journal.Shifts = append(journal.Shifts, &ShiftLeg{Step: StepSplit, Amount: amount})
+		}
+		journal.Step = StepSplit
 	})
+}
+
+func (c Currencies) withDefaults() Currencies {
+	c.Fiat = strings.ToUpper(c.Fiat)
+	c.Base = strings.ToUpper(c.Base)
+	c.Quote = strings.ToUpper(c.Quote)
+	c.BaseNetwork = strings.ToLower(c.BaseNetwork)
+	c.QuoteNetwork = strings.ToLower(c.QuoteNetwork)
+
+	if c.Fiat == "" {
+		c.Fiat = DefaultFiatCurrency
+	}
+	if c.Base == "" {
+		c.Base = DefaultBaseCurrency
+	}
+	if c.Quote == "" {
+		c.Quote = DefaultQuoteCurrency
+	}
+	return c
+}
+
+// pairBase and pairQuote name the coins for SideShift's pair endpoint, which takes coin-network to pick a network.
+func (c Currencies) pairBase() string {
+	return coinNetwork(c.Base, c.BaseNetwork)
+}
+
+func (c Currencies) pairQuote() string {
+	return coinNetwork(c.Quote, c.QuoteNetwork)
+}
+
+func coinNetwork(coin, network string) string {
+	if network == "" {
+		return coin
+	}
+	return coin + "-" + network
+}
+
+// checkNetworks makes sure SideShift is using the networks that were asked for, since sending on the wrong chain
+// loses the funds.
+func (c Currencies) checkNetworks(depositNetwork, settleNetwork string) error {
+	if c.BaseNetwork != "" && !strings.EqualFold(c.BaseNetwork, depositNetwork) {
+		return fmt.Errorf("sideshift would take %v deposits on %v, not %v", c.Base, depositNetwork, c.BaseNetwork)
+	}
+	if c.QuoteNetwork != "" && !strings.EqualFold(c.QuoteNetwork, settleNetwork) {
+		return fmt.Errorf("sideshift would settle %v on %v, not %v", c.Quote, settleNetwork, c.QuoteNetwork)
+	}
+	return nil
+}
+
+// settleAddress checks the settle address can be used before any funds move, combining it with paymentID into an
+// integrated address if one is given. Only Monero addresses can be checked locally, anything else is left to SideShift.
+func settleAddress(address, paymentID, currency string) (string, error) {
+	if currency != "XMR" {
+		if paymentID != "" {
+			return "", fmt.Errorf("payment IDs are only supported when settling in XMR")
+		}
+		return address, nil
+	}
+
+	parsed, err := monero.ParseAddress(address)
 	if err != nil {
-		log.Fatalf("%v", err)
+		return "", fmt.Errorf("invalid monero address %v: %w", address, err)
+	}
+	if parsed.Network != monero.Mainnet {
+		return "", fmt.Errorf("monero address %v is for %v, not mainnet", address, parsed.Network)
+	}
+	if paymentID == "" {
+		return address, nil
 	}
 
-	log.Info("waiting for shift completion")
-	shiftResult, err := c.ssClient.PollShift(shift.ID)
+	decoded, err := hex.DecodeString(paymentID)
 	if err != nil {
-		log.Fatalf("%v", err)
+		return "", fmt.Errorf("invalid payment ID %v: %w", paymentID, err)
+	}
+	integrated, err := parsed.Integrated(decoded)
+	if err != nil {
+		return "", err
 	}
 
-	log.Infof("%+v", shiftResult)
+	return integrated.String(), nil
 }
 
-func (c *Converter) getBalance(currency string) (float64, error) {
-	account, err := c.cbClient.GetAccountByCode(currency)
+// paymentID returns the hex encoded payment ID embedded in address, or an empty string if it isn't an integrated
+// Monero address.
+func paymentID(address string) string {
+	parsed, err := monero.ParseAddress(address)
+	if err != nil || parsed.Type != monero.Integrated {
+		return ""
+	}
+	return hex.EncodeToString(parsed.PaymentID)
+}
+
+// validateCurrencies checks that the route is actually tradeable before any money moves.
+func (c *Converter) validateCurrencies(ctx context.Context, currencies Currencies) error {
+	productID := fmt.Sprintf("%v-%v", currencies.Base, currencies.Fiat)
+
+	product, err := c.cbClient.GetProduct(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if product.TradingDisabled || product.IsDisabled || product.CancelOnly {
+		return fmt.Errorf("trading for product %v is disabled", productID)
+	}
+	if !strings.EqualFold(product.BaseCurrencyID, currencies.Base) || !strings.EqualFold(product.QuoteCurrencyID, currencies.Fiat) {
+		return fmt.Errorf("product %v trades %v for %v, not %v for %v", productID, product.BaseCurrencyID, product.QuoteCurrencyID, currencies.Base, currencies.Fiat)
+	}
+
+	pair, err := c.ssClient.GetPair(ctx, currencies.pairBase(), currencies.pairQuote())
 	if err != nil {
-		return 0, err
+		return err
+	}
+	if !strings.EqualFold(pair.DepositCoin, currencies.Base) || !strings.EqualFold(pair.SettleCoin, currencies.Quote) {
+		return fmt.Errorf("sideshift pair is %v to %v, not %v to %v", pair.DepositCoin, pair.SettleCoin, currencies.Base, currencies.Quote)
+	}
+	if err := currencies.checkNetworks(pair.DepositNetwork, pair.SettleNetwork); err != nil {
+		return err
+	}
+
+	// make sure both coinbase accounts exist so we don't find out after buying
+	for _, currency := range []string{currencies.Fiat, currencies.Base} {
+		if _, err := c.cbClient.GetAccountByCode(ctx, currency); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Converter) getBalance(ctx context.Context, currency string) (decimal.Decimal, error) {
+	account, err := c.cbClient.GetAccountByCode(ctx, currency)
+	if err != nil {
+		return decimal.Zero, err
 	}
 
 	return account.Balance.Amount, nil
 }
 
-func (c *Converter) createOrder(currency string) error {
-	productID := fmt.Sprintf("%v-%v", currency, fiatCurrency)
+type orderPlan struct {
+	product     *coinbase.ProductResponse
+	pair        *sideshift.PairResponse
+	fiatBalance decimal.Decimal
+	baseBalance decimal.Decimal
+	// nil if the fiat balance is too small to bother placing an order
+	order *coinbase.AdvancedOrderRequest
+	// base currency the order is expected to buy at market
+	bought decimal.Decimal
+}
+
+// planOrder runs the pre-flight checks for buying the base currency and works out the order to place, without placing
+// it.
+func (c *Converter) planOrder(ctx context.Context, currencies Currencies, opts Opts) (*orderPlan, error) {
+	productID := fmt.Sprintf("%v-%v", currencies.Base, currencies.Fiat)
 	log.Infof("using product %v", productID)
 
-	product, err := c.cbClient.GetProduct(productID)
+	product, err := c.cbClient.GetProduct(ctx, productID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if product.TradingDisabled {
-		return fmt.Errorf("trading for product %v is disabled", productID)
+		return nil, fmt.Errorf("trading for product %v is disabled", productID)
 	}
 
-	pair, err := c.ssClient.GetPair(baseCurrency, quoteCurrency)
+	pair, err := c.ssClient.GetPair(ctx, currencies.pairBase(), currencies.pairQuote())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	log.Infof("shift minimum is %v, maximum is %v", pair.Min, pair.Max)
 
-	fiatBalance, err := c.getBalance(fiatCurrency)
+	fiatBalance, err := c.getBalance(ctx, currencies.Fiat)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	log.Infof("fiat balance is %v", fiatBalance)
 
+	baseAccount, err := c.cbClient.GetAccountByCode(ctx, currencies.Base)
+	if err != nil {
+		return nil, err
+	}
+	baseBalance := baseAccount.Balance.Amount
+	log.Infof("base balance is %v", baseBalance)
+
+	plan := &orderPlan{
+		product:     product,
+		pair:        pair,
+		fiatBalance: fiatBalance,
+		baseBalance: baseBalance,
+	}
+
 	// quote means fiat here thanks to coinbase inverting things
-	if fiatBalance > 0 && fiatBalance > product.QuoteMinSize {
+	if fiatBalance.IsPositive() && fiatBalance.GreaterThan(product.QuoteMinSize) {
 		// clamp amount to maximum order size for the millionaires
-		orderVolumeFiat := math.Min(fiatBalance, product.QuoteMaxSize)
+		orderVolumeFiat := toIncrement(decimal.Min(fiatBalance, product.QuoteMaxSize), product.QuoteIncrement, decimal.Decimal.Floor)
 
-		baseBalance, err := c.getBalance(baseCurrency)
+		// estimate if we'll have enough to shift if we place a market order, maker orders can only do better
+		summary, err := c.cbClient.GetTransactionSummary(ctx)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		book, err := c.cbClient.GetBestBidAsk(ctx, productID)
+		if err != nil {
+			return nil, err
 		}
-		log.Infof("base balance is %v", baseBalance)
-		// estimate if we'll have enough to shift if we place a market order
-		if baseBalance+(product.Price/orderVolumeFiat) < pair.Min {
-			return fmt.Errorf("%v balance too low to initiate shift (minimum %v)", baseCurrency, pair.Min)
+		plan.bought = estimateBought(orderVolumeFiat, summary.FeeTier.TakerFeeRate, product.Price, askLevels(book))
+
+		places := int32(baseAccount.Currency.Exponent)
+		amount, err := shiftableAmount(baseBalance.Add(plan.bought), plan.bought, opts.sendFee(currencies.Base), pair.Max, places, opts)
+		if err != nil {
+			return nil, fmt.Errorf("expecting to buy %v %v: %w", plan.bought, currencies.Base, err)
+		}
+		log.Infof("expecting to buy %v %v and shift %v", plan.bought, currencies.Base, amount)
+		if amount.LessThan(pair.Min) {
+			return nil, fmt.Errorf("estimated %v %v to shift is below shift minimum %v", amount, currencies.Base, pair.Min)
 		}
 
-		log.Infof("placing order for %v %v", orderVolumeFiat, baseCurrency)
 		order := coinbase.AdvancedOrderRequest{
 			ClientOrderID: uuid.New().String(),
 			ProductID:     productID,
 			Side:          "BUY",
 		}
-		order.OrderConfiguration.MarketMarketIOC.QuoteSize = orderVolumeFiat
+		order.OrderConfiguration.MarketMarketIOC = &coinbase.MarketMarketIOC{QuoteSize: orderVolumeFiat}
+		plan.order = &order
+	}
 
-		resp, err := c.cbClient.CreateAdvancedOrder(order)
-		if err != nil {
-			return err
+	return plan, nil
+}
+
+// createOrder buys the base currency with the fiat balance. The returned order is nil if the fiat balance was too
+// small to place one.
+func (c *Converter) createOrder(ctx context.Context, journal *Journal, opts Opts) (*coinbase.AdvancedOrderResponse, *OrderFill, error) {
+	currencies := journal.Currencies
+
+	plan, err := c.planOrder(ctx, currencies, opts)
+	if err != nil {
+		return nil, nil, &PreflightError{err}
+	}
+
+	var (
+		order *coinbase.AdvancedOrderResponse
+		fill  *OrderFill
+	)
+	switch {
+	case plan.order != nil && opts.Maker:
+		if order, fill, err = c.createMakerOrder(ctx, journal, plan, opts); err != nil {
+			return nil, nil, err
 		}
-		if !resp.Success {
-			return fmt.Errorf("advanced order failed: %v", resp.ErrorResponse.Message)
+	case plan.order != nil:
+		if order, fill, err = c.createMarketOrders(ctx, journal, plan); err != nil {
+			return nil, nil, err
 		}
-
-		log.Info("order succeeded")
 	}
 
-	baseBalance, err := c.getBalance(baseCurrency)
-	if err != nil {
-		return err
+	baseBalance := plan.baseBalance
+	if fill != nil && fill.FilledSize.IsPositive() {
+		// allow half an increment of slack for rounding in the fill sizes
+		expected := plan.baseBalance.Add(fill.FilledSize).Sub(plan.product.BaseIncrement.Div(decimal.NewFromInt(2)))
+		if baseBalance, err = c.waitForBalance(ctx, currencies.Base, expected); err != nil {
+			return nil, nil, err
+		}
 	}
 	log.Infof("base balance is %v", baseBalance)
 	// additional check before we start the shift just in case the price moved since the pre-flight check
-	if pair.Min > baseBalance {
-		return fmt.Errorf("%v balance too low to initiate shift (minimum %v)", baseCurrency, pair.Min)
-	}
-	if pair.Max < baseBalance {
-		return fmt.Errorf("%v balance too high to initiate shift (maximum %v)", baseCurrency, pair.Max)
+	if plan.pair.Min.GreaterThan(baseBalance) {
+		err := fmt.Errorf("%v balance too low to initiate shift (minimum %v)", currencies.Base, plan.pair.Min)
+		if plan.order == nil {
+			// nothing was bought, so this is still a pre-flight check
+			err = &PreflightError{err}
+		}
+		return nil, nil, err
 	}
 
-	return nil
+	return order, fill, nil
 }
 
-func (c *Converter) getRefundAddress(currency string) (string, error) {
-	addresses, err := c.cbClient.GetAddresses(baseCurrency)
+func (c *Converter) getRefundAddress(ctx context.Context, currency string) (string, error) {
+	addresses, err := c.cbClient.GetAddresses(ctx, currency)
 	if err != nil {
 		return "", err
 	}
@@ -181,7 +635,7 @@ func (c *Converter) getRefundAddress(currency string) (string, error) {
 	if len(*addresses) > 0 {
 		refundAddress = (*addresses)[0].Address
 	} else {
-		address, err := c.cbClient.CreateAddress(baseCurrency)
+		address, err := c.cbClient.CreateAddress(ctx, currency)
 		if err != nil {
 			return "", err
 		}
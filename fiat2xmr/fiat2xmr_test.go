@@ -0,0 +1,129 @@
+package fiat2xmr
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cedws/fiat2xmr/monero"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAddress = "44AFFq5kSiGBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVGQBEP3A"
+
+func TestSettleAddress(t *testing.T) {
+	address, err := settleAddress(testAddress, "", "XMR")
+	assert.Nil(t, err)
+	assert.Equal(t, testAddress, address)
+	assert.Empty(t, paymentID(address))
+
+	integrated, err := settleAddress(testAddress, "0102030405060708", "XMR")
+	assert.Nil(t, err)
+	assert.NotEqual(t, testAddress, integrated)
+	assert.Equal(t, "0102030405060708", paymentID(integrated))
+
+	// already integrated addresses pass through with their payment ID intact
+	address, err = settleAddress(integrated, "", "XMR")
+	assert.Nil(t, err)
+	assert.Equal(t, integrated, address)
+
+	_, err = settleAddress(integrated, "0102030405060708", "XMR")
+	assert.NotNil(t, err)
+	_, err = settleAddress(testAddress, "not hex", "XMR")
+	assert.NotNil(t, err)
+	_, err = settleAddress(testAddress[1:], "", "XMR")
+	assert.NotNil(t, err)
+	_, err = settleAddress("bc1qaddress", "0102030405060708", "BTC")
+	assert.NotNil(t, err)
+}
+
+func TestNextSubaddress(t *testing.T) {
+	opts := Opts{
+		Address:             "48ukkZtBSBRL8iva7k3p2sBVMLWTfNwsTbW1aVh5M84g21muDCssvCHTpoZCaSc6rq8M9QLZ3sQMrMn1bq2RD2anGnyHhtq",
+		ViewKey:             "ac413c16b815899b69393d72086fa86d31e8e352895606180c4c8fadd707450a",
+		SubaddressStatePath: filepath.Join(t.TempDir(), "subaddress.json"),
+	}
+
+	first, use, err := nextSubaddress(opts)
+	assert.Nil(t, err)
+	// (0, 0) is the primary address, so the first is (0, 1)
+	assert.Equal(t, "84nvgV2eTnG1vAKbg87MnbfjWrSY3eH3s2eykmggk549C8zdNk4PPD7iv7BPfPsnoH9NjXaRhjC19FY6PBmXZUtoG5SEiY7", first)
+	// not used yet, so the same subaddress comes back
+	again, _, err := nextSubaddress(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, first, again)
+
+	assert.Nil(t, use())
+	second, _, err := nextSubaddress(opts)
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, second)
+
+	parsed, err := monero.ParseAddress(second)
+	assert.Nil(t, err)
+	assert.Equal(t, monero.Subaddress, parsed.Type)
+
+	state, err := openSubaddressState(opts.SubaddressStatePath)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), state.next(0))
+
+	// a view key from another wallet
+	opts.Address = testAddress
+	_, _, err = nextSubaddress(opts)
+	assert.ErrorIs(t, err, monero.ErrViewKeyMismatch)
+}
+
+func TestCheckNetworks(t *testing.T) {
+	currencies := Currencies{Base: "USDC", Quote: "XMR", BaseNetwork: "base"}.withDefaults()
+	assert.Equal(t, "USDC-base", currencies.pairBase())
+	assert.Equal(t, "XMR", currencies.pairQuote())
+
+	assert.Nil(t, currencies.checkNetworks("base", "monero"))
+	assert.NotNil(t, currencies.checkNetworks("ethereum", "monero"))
+
+	// without a network, whatever SideShift picks is fine
+	currencies.BaseNetwork = ""
+	assert.Nil(t, currencies.checkNetworks("ethereum", "monero"))
+}
+
+func TestConvertPreflightRemovesJournal(t *testing.T) {
+	cb := newStubAPI(map[string][]string{
+		"GET /v3/brokerage/products/LTC-GBP": {`{"product_id":"LTC-GBP","price":"50","base_currency_id":"LTC","quote_currency_id":"GBP","quote_min_size":"1","quote_max_size":"1000"}`},
+		"GET /v2/accounts/GBP":               {`{"data":{"id":"gbp","balance":{"amount":"0","currency":"GBP"}}}`},
+		"GET /v2/accounts/LTC":               {`{"data":{"id":"ltc","currency":{"exponent":8},"balance":{"amount":"0","currency":"LTC"}}}`},
+	})
+	ss := newStubAPI(map[string][]string{
+		"GET /api/v2/pair/LTC/XMR": {`{"min":"0.1","max":"100","rate":"0.5","depositCoin":"LTC","settleCoin":"XMR"}`},
+	})
+	cnv := stubConverter(t, cb, ss)
+
+	opts := Opts{Address: testAddress, JournalPath: filepath.Join(t.TempDir(), "journal.json")}
+	for i := 0; i < 2; i++ {
+		// nothing to buy with and nothing to shift, the second run must fail the same way rather than ask for a resume
+		_, err := cnv.Convert(context.Background(), opts)
+		var preflightErr *PreflightError
+		assert.ErrorAs(t, err, &preflightErr)
+		assert.Contains(t, err.Error(), "balance too low")
+
+		_, err = os.Stat(opts.JournalPath)
+		assert.True(t, errors.Is(err, fs.ErrNotExist))
+	}
+}
+
+func TestConvertBridgeAmounts(t *testing.T) {
+	cnv := NewConverter(nil, nil)
+
+	// amounts of the base currency mean nothing before the bridge is picked
+	for _, opts := range []Opts{
+		{Address: testAddress, BridgeCandidates: []string{"LTC", "BTC"}, Reserve: decimal.NewFromInt(1)},
+		{Address: testAddress, BridgeCandidates: []string{"LTC", "BTC"}, ShiftAmount: decimal.NewFromInt(1)},
+	} {
+		_, err := cnv.Convert(context.Background(), opts)
+		var preflightErr *PreflightError
+		assert.ErrorAs(t, err, &preflightErr)
+		assert.Contains(t, err.Error(), "bridge candidates")
+	}
+}
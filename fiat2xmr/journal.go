@@ -0,0 +1,187 @@
+package fiat2xmr
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Step string
+
+// Each step is recorded once it has finished, so a resumed run carries on with whatever comes after it. The
+// conversion as a whole goes from started to split, then each shift leg goes from split to settled on its own. A leg is
+// paid once SideShift has sent the quote currency, and settled once that has been confirmed.
+const (
+	StepStarted Step = "started"
+	StepOrdered Step = "ordered"
+	StepSplit   Step = "split"
+	StepQuoted  Step = "quoted"
+	StepShifted Step = "shifted"
+	StepSent    Step = "sent"
+	StepPaid    Step = "paid"
+	StepSettled Step = "settled"
+)
+
+type Journal struct {
+	mu   sync.Mutex
+	path string
+
+	Step       Step        `json:"step"`
+	Address    string      `json:"address"`
+	Currencies Currencies  `json:"currencies"`
+	ShiftType  string      `json:"shift_type,omitempty"`
+	Shifts     []*ShiftLeg `json:"shifts,omitempty"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	// Every order placed and what they bought between them, so a resumed run still counts orders from before it was
+	// interrupted.
+	OrderIDs   []string        `json:"order_ids,omitempty"`
+	FilledSize decimal.Decimal `json:"filled_size"`
+	// How much of the base balance to shift, see the fields of the same names in Opts.
+	Reserve     decimal.Decimal            `json:"reserve"`
+	ShiftAmount decimal.Decimal            `json:"shift_amount"`
+	ShiftBought bool                       `json:"shift_bought,omitempty"`
+	SendFees    map[string]decimal.Decimal `json:"send_fees,omitempty"`
+	// How the base currency is bought, see the fields of the same names in Opts.
+	Maker                bool          `json:"maker,omitempty"`
+	MakerRepriceInterval time.Duration `json:"maker_reprice_interval,omitempty"`
+	MakerTimeout         time.Duration `json:"maker_timeout,omitempty"`
+}
+
+// ShiftLeg is one of the shifts the base balance was split into to fit within the pair limits.
+type ShiftLeg struct {
+	Step           Step            `json:"step"`
+	Amount         decimal.Decimal `json:"amount"`
+	QuoteID        string          `json:"quote_id,omitempty"`
+	QuoteExpiresAt time.Time       `json:"quote_expires_at,omitempty"`
+	ShiftID        string          `json:"shift_id,omitempty"`
+	ShiftExpiresAt time.Time       `json:"shift_expires_at,omitempty"`
+	DepositAddress string          `json:"deposit_address,omitempty"`
+	DepositNetwork string          `json:"deposit_network,omitempty"`
+	DepositAmount  decimal.Decimal `json:"deposit_amount"`
+	TxID           string          `json:"tx_id,omitempty"`
+	SettleHash     string          `json:"settle_hash,omitempty"`
+	SettleAmount   decimal.Decimal `json:"settle_amount"`
+	Requotes       int             `json:"requotes,omitempty"`
+	// Set before the send is made, so a send that may have gone through is retried with the same key. Cleared once
+	// Coinbase refuses the send, since nothing went out.
+	Idem string `json:"idem,omitempty"`
+}
+
+// requote throws away the quote and any shift made from it so the leg starts again with a fresh quote. It must only
+// be used before anything has been sent to the shift.
+func (l *ShiftLeg) requote() {
+	l.QuoteID = ""
+	l.QuoteExpiresAt = time.Time{}
+	l.ShiftID = ""
+	l.ShiftExpiresAt = time.Time{}
+	l.DepositAddress = ""
+	l.DepositNetwork = ""
+	l.DepositAmount = decimal.Zero
+	l.Idem = ""
+	l.Requotes++
+	l.Step = StepSplit
+}
+
+// restoreOpts returns opts with the options the conversion was started with put back.
+func (j *Journal) restoreOpts(opts Opts) Opts {
+	opts.Reserve = j.Reserve
+	opts.ShiftAmount = j.ShiftAmount
+	opts.ShiftBought = j.ShiftBought
+	opts.SendFees = j.SendFees
+	opts.Maker = j.Maker
+	opts.MakerRepriceInterval = j.MakerRepriceInterval
+	opts.MakerTimeout = j.MakerTimeout
+	return opts
+}
+
+func NewJournal(path string) *Journal {
+	return &Journal{path: path, Step: StepStarted}
+}
+
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	journal := &Journal{path: path}
+	if err := json.NewDecoder(file).Decode(journal); err != nil {
+		return nil, err
+	}
+
+	return journal, nil
+}
+
+func (j *Journal) Done() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.Step == StepSettled
+}
+
+// Save persists the journal.
+func (j *Journal) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.save()
+}
+
+// Update applies fn and persists the journal. Legs may be run concurrently, so they must only be changed through here.
+func (j *Journal) Update(fn func()) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	fn()
+	return j.save()
+}
+
+// Advance records that step has finished and persists the journal.
+func (j *Journal) Advance(step Step) error {
+	return j.Update(func() {
+		j.Step = step
+	})
+}
+
+// Remove deletes the journal, for a conversion that stopped before anything was bought and so has nothing to resume.
+func (j *Journal) Remove() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return os.Remove(j.path)
+}
+
+func (j *Journal) save() error {
+	j.UpdatedAt = time.Now()
+	return saveJSON(j.path, j)
+}
+
+// saveJSON writes v to a temporary file and renames it into place so a crash mid-write can't corrupt it.
+func saveJSON(path string, v any) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
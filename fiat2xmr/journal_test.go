@@ -0,0 +1,176 @@
+package fiat2xmr
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournalRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+
+	journal := NewJournal(path)
+	journal.Address = "address"
+	journal.Shifts = []*ShiftLeg{{Step: StepShifted, ShiftID: "shift", DepositAmount: decimal.RequireFromString("1.5")}}
+	assert.Nil(t, journal.Advance(StepSplit))
+
+	opened, err := OpenJournal(path)
+	assert.Nil(t, err)
+	assert.Equal(t, StepSplit, opened.Step)
+	assert.Equal(t, "address", opened.Address)
+	assert.Len(t, opened.Shifts, 1)
+	assert.Equal(t, StepShifted, opened.Shifts[0].Step)
+	assert.Equal(t, "shift", opened.Shifts[0].ShiftID)
+	assert.Equal(t, "1.5", opened.Shifts[0].DepositAmount.String())
+	assert.False(t, opened.Done())
+}
+
+func TestJournalRestoreOpts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+
+	journal := NewJournal(path)
+	journal.Reserve = decimal.RequireFromString("0.5")
+	journal.ShiftBought = true
+	journal.Maker = true
+	journal.MakerTimeout = time.Hour
+	journal.SendFees = map[string]decimal.Decimal{"LTC": decimal.RequireFromString("0.001")}
+	assert.Nil(t, journal.Save())
+
+	opened, err := OpenJournal(path)
+	assert.Nil(t, err)
+
+	// resume isn't given these options, they have to come from the journal
+	opts := opened.restoreOpts(Opts{JournalPath: path})
+	assert.Equal(t, path, opts.JournalPath)
+	assert.Equal(t, "0.5", opts.Reserve.String())
+	assert.True(t, opts.ShiftBought)
+	assert.Equal(t, "0.001", opts.sendFee("ltc").String())
+	assert.True(t, opts.Maker)
+	assert.Equal(t, time.Hour, opts.MakerTimeout)
+}
+
+// resumeShifted writes a journal with a single leg waiting to be sent and resumes it against cb and ss.
+func resumeShifted(t *testing.T, cb, ss *stubAPI, leg ShiftLeg) (*Journal, error) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+
+	journal := NewJournal(path)
+	journal.Address = testAddress
+	journal.Currencies = Currencies{}.withDefaults()
+	journal.Step = StepSplit
+	leg.Step = StepShifted
+	leg.ShiftID = "1"
+	leg.DepositAddress = "ltcaddress"
+	leg.DepositNetwork = "litecoin"
+	leg.DepositAmount = decimal.RequireFromString("1.5")
+	journal.Shifts = []*ShiftLeg{&leg}
+	assert.Nil(t, journal.Save())
+
+	cnv := stubConverter(t, cb, ss)
+	_, err := cnv.Resume(context.Background(), Opts{JournalPath: path, ShiftPollInterval: time.Millisecond})
+
+	opened, openErr := OpenJournal(path)
+	assert.Nil(t, openErr)
+	return opened, err
+}
+
+const (
+	stubAccount = `{"data":{"id":"ltc","currency":{"exponent":8},"balance":{"amount":"2","currency":"LTC"}}}`
+	stubSent    = `{"data":{"id":"tx","type":"send","status":"pending","to":{"address":"ltcaddress"}}}`
+	stubSettled = `{"id":"1","status":"settled","settleHash":"hash","settleAmount":"0.75"}`
+	sendRoute   = "POST /v2/accounts/ltc/transactions"
+	listRoute   = "GET /v2/accounts/ltc/transactions"
+	shiftRoute  = "GET /api/v2/shifts/1"
+)
+
+func sentTx(t *testing.T, body string) (tx struct{ Idem, Network string }) {
+	assert.Nil(t, json.Unmarshal([]byte(body), &tx))
+	return tx
+}
+
+func TestResumeShifted(t *testing.T) {
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	waiting := `{"id":"1","status":"waiting","expiresAt":"` + expires + `"}`
+
+	t.Run("never sent", func(t *testing.T) {
+		cb := newStubAPI(map[string][]string{"GET /v2/accounts/LTC": {stubAccount}, sendRoute: {stubSent}})
+		ss := newStubAPI(map[string][]string{shiftRoute: {waiting, stubSettled}})
+
+		journal, err := resumeShifted(t, cb, ss, ShiftLeg{ShiftExpiresAt: time.Now().Add(time.Hour)})
+		assert.Nil(t, err)
+		assert.True(t, journal.Done())
+		assert.Equal(t, "tx", journal.Shifts[0].TxID)
+		assert.Equal(t, "hash", journal.Shifts[0].SettleHash)
+
+		sends := cb.calls(sendRoute)
+		assert.Len(t, sends, 1)
+		tx := sentTx(t, sends[0])
+		assert.NotEmpty(t, tx.Idem)
+		assert.Equal(t, journal.Shifts[0].Idem, tx.Idem)
+		assert.Equal(t, "litecoin", tx.Network)
+	})
+
+	t.Run("retried with the same idem", func(t *testing.T) {
+		cb := newStubAPI(map[string][]string{"GET /v2/accounts/LTC": {stubAccount}, sendRoute: {stubSent}})
+		ss := newStubAPI(map[string][]string{shiftRoute: {waiting, stubSettled}})
+
+		journal, err := resumeShifted(t, cb, ss, ShiftLeg{ShiftExpiresAt: time.Now().Add(time.Hour), Idem: "earlier"})
+		assert.Nil(t, err)
+		assert.True(t, journal.Done())
+
+		sends := cb.calls(sendRoute)
+		assert.Len(t, sends, 1)
+		assert.Equal(t, "earlier", sentTx(t, sends[0]).Idem)
+	})
+
+	t.Run("earlier send found before expiry", func(t *testing.T) {
+		cb := newStubAPI(map[string][]string{
+			"GET /v2/accounts/LTC": {stubAccount},
+			listRoute:              {`{"data":[{"id":"other","type":"send","to":{"address":"elsewhere"}},{"id":"tx","type":"send","status":"pending","to":{"address":"ltcaddress"}}]}`},
+		})
+		ss := newStubAPI(map[string][]string{shiftRoute: {waiting, stubSettled}})
+
+		journal, err := resumeShifted(t, cb, ss, ShiftLeg{ShiftExpiresAt: time.Now().Add(time.Minute), Idem: "earlier"})
+		assert.Nil(t, err)
+		assert.True(t, journal.Done())
+		assert.Equal(t, "tx", journal.Shifts[0].TxID)
+		// sending again could have paid a shift about to expire
+		assert.Empty(t, cb.calls(sendRoute))
+	})
+
+	t.Run("earlier send missing before expiry", func(t *testing.T) {
+		cb := newStubAPI(map[string][]string{
+			"GET /v2/accounts/LTC": {stubAccount},
+			listRoute:              {`{"data":[{"id":"failed","type":"send","status":"failed","to":{"address":"ltcaddress"}}]}`},
+		})
+		// no quotes, so the resumed run stops once the leg is started over
+		ss := newStubAPI(map[string][]string{shiftRoute: {waiting}})
+
+		journal, err := resumeShifted(t, cb, ss, ShiftLeg{ShiftExpiresAt: time.Now().Add(time.Minute), Idem: "earlier"})
+		assert.NotNil(t, err)
+		assert.Empty(t, cb.calls(sendRoute))
+		assert.Equal(t, StepSplit, journal.Shifts[0].Step)
+		assert.Equal(t, 1, journal.Shifts[0].Requotes)
+		assert.Empty(t, journal.Shifts[0].Idem)
+	})
+
+	t.Run("refused for 2FA", func(t *testing.T) {
+		cb := newStubAPI(map[string][]string{
+			"GET /v2/accounts/LTC": {stubAccount},
+			sendRoute:              {`402 {"errors":[{"id":"two_factor_required","message":"That action requires two-factor authentication"}]}`},
+		})
+		ss := newStubAPI(map[string][]string{shiftRoute: {waiting}})
+
+		journal, err := resumeShifted(t, cb, ss, ShiftLeg{ShiftExpiresAt: time.Now().Add(time.Hour)})
+		var sendErr *SendError
+		assert.ErrorAs(t, err, &sendErr)
+		assert.Len(t, cb.calls(sendRoute), 1)
+		// nothing went out, so the next resume starts over and checks the expiry again
+		assert.Equal(t, StepShifted, journal.Shifts[0].Step)
+		assert.Empty(t, journal.Shifts[0].Idem)
+	})
+}
@@ -0,0 +1,201 @@
+package fiat2xmr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/cedws/fiat2xmr/coinbase"
+	"github.com/cedws/fiat2xmr/internal/retry"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultMakerRepriceInterval = time.Minute
+	defaultMakerTimeout         = 15 * time.Minute
+)
+
+// createMakerOrder buys the base currency with post-only limit orders just inside the best bid. Orders that haven't
+// filled within the re-price interval are cancelled and placed again at the new best bid, and orders keep being placed
+// until the fiat balance is spent since each is capped at the maximum size. Whatever is left once the timeout passes is
+// bought at market.
+func (c *Converter) createMakerOrder(ctx context.Context, journal *Journal, plan *orderPlan, opts Opts) (*coinbase.AdvancedOrderResponse, *OrderFill, error) {
+	repriceInterval := opts.MakerRepriceInterval
+	if repriceInterval <= 0 {
+		repriceInterval = defaultMakerRepriceInterval
+	}
+	timeout := opts.MakerTimeout
+	if timeout <= 0 {
+		timeout = defaultMakerTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	summary, err := c.cbClient.GetTransactionSummary(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	feeRate := summary.FeeTier.MakerFeeRate
+	log.Infof("maker fee rate is %v", feeRate)
+
+	var last *coinbase.AdvancedOrderResponse
+	total := &OrderFill{}
+	fiatBalance := plan.fiatBalance
+
+	for time.Now().Before(deadline) {
+		order, err := c.planLimitOrder(ctx, plan.product, fiatBalance, feeRate)
+		if err != nil {
+			return last, total, err
+		}
+		if order == nil {
+			// what's left is below the minimum order size
+			return last, total, nil
+		}
+
+		limit := order.OrderConfiguration.LimitLimitGTC
+		log.Infof("placing maker order for %v %v at %v", limit.BaseSize, order.ProductID, limit.LimitPrice)
+		resp, err := c.cbClient.CreateAdvancedOrder(ctx, *order)
+		if err != nil {
+			return last, total, err
+		}
+		if !resp.Success {
+			if isPostOnlyRejection(resp) {
+				// the book moved under us, try again at the new price
+				log.Warn("maker order would have taken liquidity, re-pricing")
+				if err := retry.Sleep(ctx, orderPollInterval); err != nil {
+					return last, total, err
+				}
+				continue
+			}
+			return last, total, fmt.Errorf("advanced order failed: %v", resp.ErrorResponse.Message)
+		}
+		last = resp
+
+		if err := journal.Update(func() { journal.OrderIDs = append(journal.OrderIDs, resp.OrderID) }); err != nil {
+			return last, total, err
+		}
+
+		until := time.Now().Add(repriceInterval)
+		if until.After(deadline) {
+			until = deadline
+		}
+
+		status, err := c.waitForOrder(ctx, resp.OrderID, until)
+		if err != nil {
+			return last, total, err
+		}
+		if status.Order.Status != OrderStatusFilled {
+			if err := c.cancelOrder(ctx, resp.OrderID); err != nil {
+				return last, total, err
+			}
+		}
+
+		fill, err := c.fillOrder(ctx, resp.OrderID)
+		if err != nil {
+			return last, total, err
+		}
+		total.add(fill)
+
+		// even a filled order may have been capped at the maximum size, so carry on until the fiat is spent
+		if fiatBalance, err = c.getBalance(ctx, journal.Currencies.Fiat); err != nil {
+			return last, total, err
+		}
+		if fiatBalance.LessThanOrEqual(plan.product.QuoteMinSize) {
+			return last, total, nil
+		}
+		if status.Order.Status == OrderStatusFilled {
+			log.Infof("fiat balance %v remains after a filled maker order, placing another", fiatBalance)
+		}
+	}
+
+	if fiatBalance.LessThanOrEqual(plan.product.QuoteMinSize) {
+		return last, total, nil
+	}
+
+	log.Warn("maker orders timed out, buying the remainder at market")
+	order := coinbase.AdvancedOrderRequest{
+		ClientOrderID: uuid.New().String(),
+		ProductID:     plan.product.ProductID,
+		Side:          "BUY",
+	}
+	order.OrderConfiguration.MarketMarketIOC = &coinbase.MarketMarketIOC{
+		QuoteSize: toIncrement(decimal.Min(fiatBalance, plan.product.QuoteMaxSize), plan.product.QuoteIncrement, decimal.Decimal.Floor),
+	}
+
+	remainder := *plan
+	remainder.order = &order
+	resp, fill, err := c.createMarketOrders(ctx, journal, &remainder)
+	total.add(fill)
+	if resp == nil {
+		resp = last
+	}
+
+	return resp, total, err
+}
+
+// planLimitOrder works out a post-only limit order one increment above the best bid that spends up to fiatBalance,
+// leaving room for the maker fee. It returns nil if the order would be below the product's minimum size.
+func (c *Converter) planLimitOrder(ctx context.Context, product *coinbase.ProductResponse, fiatBalance, feeRate decimal.Decimal) (*coinbase.AdvancedOrderRequest, error) {
+	book, err := c.cbClient.GetBestBidAsk(ctx, product.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if len(book.Pricebooks) == 0 || len(book.Pricebooks[0].Bids) == 0 || len(book.Pricebooks[0].Asks) == 0 {
+		return nil, fmt.Errorf("order book for %v is empty", product.ProductID)
+	}
+	bid := book.Pricebooks[0].Bids[0].Price
+	ask := book.Pricebooks[0].Asks[0].Price
+
+	price := toIncrement(bid.Add(product.QuoteIncrement), product.QuoteIncrement, roundHalfUp)
+	if price.GreaterThanOrEqual(ask) {
+		// spread is a single tick, joining the bid is the best we can do without crossing
+		price = bid
+	}
+
+	orderVolumeFiat := decimal.Min(fiatBalance, product.QuoteMaxSize)
+	baseSize := toIncrement(orderVolumeFiat.Div(price.Mul(feeRate.Add(decimal.NewFromInt(1)))), product.BaseIncrement, decimal.Decimal.Floor)
+	if baseSize.LessThan(product.BaseMinSize) || baseSize.Mul(price).LessThan(product.QuoteMinSize) {
+		return nil, nil
+	}
+
+	order := coinbase.AdvancedOrderRequest{
+		ClientOrderID: uuid.New().String(),
+		ProductID:     product.ProductID,
+		Side:          "BUY",
+	}
+	order.OrderConfiguration.LimitLimitGTC = &coinbase.LimitLimitGTC{
+		BaseSize:   baseSize,
+		LimitPrice: price,
+		PostOnly:   true,
+	}
+
+	return &order, nil
+}
+
+func isPostOnlyRejection(resp *coinbase.AdvancedOrderResponse) bool {
+	for _, reason := range []string{
+		resp.FailureReason,
+		resp.ErrorResponse.Error,
+		resp.ErrorResponse.PreviewFailureReason,
+		resp.ErrorResponse.NewOrderFailureReason,
+	} {
+		if strings.Contains(reason, "POST_ONLY") {
+			return true
+		}
+	}
+	return false
+}
+
+// toIncrement rounds v to a multiple of increment with round.
+func toIncrement(v, increment decimal.Decimal, round func(decimal.Decimal) decimal.Decimal) decimal.Decimal {
+	if !increment.IsPositive() {
+		return v
+	}
+	return round(v.Div(increment)).Mul(increment)
+}
+
+func roundHalfUp(v decimal.Decimal) decimal.Decimal {
+	return v.Round(0)
+}
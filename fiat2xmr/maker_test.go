@@ -0,0 +1,19 @@
+package fiat2xmr
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToIncrement(t *testing.T) {
+	d := decimal.RequireFromString
+
+	assert.Equal(t, "0.3", toIncrement(d("0.1").Add(d("0.2")), d("0.01"), decimal.Decimal.Floor).String())
+	assert.Equal(t, "0.3", toIncrement(d("0.3"), d("0.1"), decimal.Decimal.Floor).String())
+	assert.Equal(t, "123.45", toIncrement(d("123.456"), d("0.01"), decimal.Decimal.Floor).String())
+	assert.Equal(t, "123.46", toIncrement(d("123.456"), d("0.01"), roundHalfUp).String())
+	assert.Equal(t, "1.5", toIncrement(d("1.5"), decimal.Zero, decimal.Decimal.Floor).String())
+	assert.Equal(t, "0.00123", toIncrement(d("0.001239"), d("0.00001"), decimal.Decimal.Floor).String())
+}
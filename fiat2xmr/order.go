@@ -0,0 +1,220 @@
+package fiat2xmr
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/cedws/fiat2xmr/coinbase"
+	"github.com/cedws/fiat2xmr/internal/retry"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	orderPollInterval = 5 * time.Second
+	// how long to wait for an order that should fill straight away, like a market order, before giving up on it
+	orderFillTimeout = 5 * time.Minute
+)
+
+const (
+	OrderStatusOpen      = "OPEN"
+	OrderStatusFilled    = "FILLED"
+	OrderStatusCancelled = "CANCELLED"
+	OrderStatusExpired   = "EXPIRED"
+	OrderStatusFailed    = "FAILED"
+)
+
+// OrderFill summarises what one or more orders actually bought once they finished.
+type OrderFill struct {
+	OrderIDs     []string
+	FilledSize   decimal.Decimal
+	AveragePrice decimal.Decimal
+	Fees         decimal.Decimal
+}
+
+func (f *OrderFill) add(other *OrderFill) {
+	if other.FilledSize.IsPositive() {
+		total := f.FilledSize.Add(other.FilledSize)
+		f.AveragePrice = f.AveragePrice.Mul(f.FilledSize).Add(other.AveragePrice.Mul(other.FilledSize)).Div(total)
+		f.FilledSize = total
+	}
+	f.Fees = f.Fees.Add(other.Fees)
+	f.OrderIDs = append(f.OrderIDs, other.OrderIDs...)
+}
+
+func (c *Converter) createMarketOrder(ctx context.Context, order coinbase.AdvancedOrderRequest) (*coinbase.AdvancedOrderResponse, error) {
+	log.Infof("placing market order for %v %v", order.OrderConfiguration.MarketMarketIOC.QuoteSize, order.ProductID)
+	resp, err := c.cbClient.CreateAdvancedOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("advanced order failed: %v", resp.ErrorResponse.Message)
+	}
+
+	log.Info("order succeeded")
+	return resp, nil
+}
+
+// createMarketOrders buys with market orders until the fiat balance is spent, since a single order can't be larger than
+// the product's maximum size.
+func (c *Converter) createMarketOrders(ctx context.Context, journal *Journal, plan *orderPlan) (*coinbase.AdvancedOrderResponse, *OrderFill, error) {
+	var last *coinbase.AdvancedOrderResponse
+	total := &OrderFill{}
+	order := *plan.order
+
+	for {
+		resp, err := c.createMarketOrder(ctx, order)
+		if err != nil {
+			return last, total, err
+		}
+		last = resp
+
+		if err := journal.Update(func() { journal.OrderIDs = append(journal.OrderIDs, resp.OrderID) }); err != nil {
+			return last, total, err
+		}
+
+		fill, err := c.fillOrder(ctx, resp.OrderID)
+		if err != nil {
+			return last, total, err
+		}
+		total.add(fill)
+
+		if order.OrderConfiguration.MarketMarketIOC.QuoteSize.LessThan(plan.product.QuoteMaxSize) {
+			return last, total, nil
+		}
+
+		fiatBalance, err := c.getBalance(ctx, journal.Currencies.Fiat)
+		if err != nil {
+			return last, total, err
+		}
+		if fiatBalance.LessThanOrEqual(plan.product.QuoteMinSize) {
+			return last, total, nil
+		}
+		log.Infof("fiat balance %v remains after a maximum size order, placing another", fiatBalance)
+
+		order = coinbase.AdvancedOrderRequest{
+			ClientOrderID: uuid.New().String(),
+			ProductID:     plan.product.ProductID,
+			Side:          "BUY",
+		}
+		order.OrderConfiguration.MarketMarketIOC = &coinbase.MarketMarketIOC{
+			QuoteSize: toIncrement(decimal.Min(fiatBalance, plan.product.QuoteMaxSize), plan.product.QuoteIncrement, decimal.Decimal.Floor),
+		}
+	}
+}
+
+// waitForOrder polls an order until it reaches a terminal status or until passes, whichever happens first.
+func (c *Converter) waitForOrder(ctx context.Context, orderID string, until time.Time) (*coinbase.OrderResponse, error) {
+	for {
+		order, err := c.cbClient.GetOrder(ctx, orderID)
+		if err != nil {
+			return nil, err
+		}
+
+		switch order.Order.Status {
+		case OrderStatusFilled, OrderStatusCancelled, OrderStatusExpired, OrderStatusFailed:
+			return order, nil
+		}
+		if !time.Now().Before(until) {
+			return order, nil
+		}
+
+		if err := retry.Sleep(ctx, orderPollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// fillOrder waits for an order to finish and totals up its fills.
+func (c *Converter) fillOrder(ctx context.Context, orderID string) (*OrderFill, error) {
+	order, err := c.waitForOrder(ctx, orderID, time.Now().Add(orderFillTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	switch order.Order.Status {
+	case OrderStatusFilled, OrderStatusCancelled, OrderStatusExpired:
+	case OrderStatusFailed:
+		return nil, fmt.Errorf("order %v failed: %v", orderID, order.Order.RejectMessage)
+	default:
+		return nil, fmt.Errorf("order %v still %v after %v", orderID, order.Order.Status, orderFillTimeout)
+	}
+
+	fills, err := c.cbClient.ListFills(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	fill := OrderFill{OrderIDs: []string{orderID}}
+	var filledValue decimal.Decimal
+	for _, f := range fills.Fills {
+		size := f.Size
+		if f.SizeInQuote && f.Price.IsPositive() {
+			size = f.Size.Div(f.Price)
+		}
+
+		fill.FilledSize = fill.FilledSize.Add(size)
+		fill.Fees = fill.Fees.Add(f.Commission)
+		filledValue = filledValue.Add(size.Mul(f.Price))
+	}
+	if fill.FilledSize.IsPositive() {
+		fill.AveragePrice = filledValue.Div(fill.FilledSize)
+	} else {
+		// fills can lag behind the order itself
+		fill.FilledSize = order.Order.FilledSize
+		fill.AveragePrice = order.Order.AverageFilledPrice
+		fill.Fees = order.Order.TotalFees
+	}
+
+	log.WithFields(log.Fields{
+		"status":        order.Order.Status,
+		"filled_size":   fill.FilledSize,
+		"average_price": fill.AveragePrice,
+		"fees":          fill.Fees,
+	}).Infof("order %v finished", orderID)
+
+	return &fill, nil
+}
+
+func (c *Converter) cancelOrder(ctx context.Context, orderID string) error {
+	resp, err := c.cbClient.CancelOrders(ctx, coinbase.CancelOrdersRequest{OrderIDs: []string{orderID}})
+	if err != nil {
+		return err
+	}
+
+	for _, result := range resp.Results {
+		// usually because the order already filled, which is fine
+		if !result.Success {
+			log.Warnf("could not cancel order %v: %v", result.OrderID, result.FailureReason)
+		}
+	}
+
+	return nil
+}
+
+// waitForBalance polls until the balance of currency reaches at least amount, since fills don't show up in account
+// balances straight away.
+func (c *Converter) waitForBalance(ctx context.Context, currency string, amount decimal.Decimal) (decimal.Decimal, error) {
+	deadline := time.Now().Add(orderFillTimeout)
+
+	for {
+		balance, err := c.getBalance(ctx, currency)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		if balance.GreaterThanOrEqual(amount) {
+			return balance, nil
+		}
+		if !time.Now().Before(deadline) {
+			return balance, fmt.Errorf("%v balance %v still below %v after %v", currency, balance, amount, orderFillTimeout)
+		}
+
+		log.Infof("waiting for %v balance %v to reach %v", currency, balance, amount)
+		if err := retry.Sleep(ctx, orderPollInterval); err != nil {
+			return decimal.Zero, err
+		}
+	}
+}
@@ -0,0 +1,502 @@
+package fiat2xmr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/cedws/fiat2xmr/coinbase"
+	"github.com/cedws/fiat2xmr/sideshift"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// a quote this close to expiring is thrown away rather than turned into a shift
+	quoteExpiryMargin = 30 * time.Second
+	// a shift this close to expiring is thrown away rather than paid, since the deposit may not confirm in time
+	shiftExpiryMargin = 2 * time.Minute
+	// give up on a leg after re-quoting this many times, something is too slow for quotes to be usable
+	maxRequotes = 3
+
+	defaultConfirmations = 10
+)
+
+// splitAmount divides total into as few parts as possible that each lie between min and max, with no more than
+// places decimal places. Anything in total beyond places is dropped.
+func splitAmount(total, min, max decimal.Decimal, places int32) ([]decimal.Decimal, error) {
+	total = total.RoundFloor(places)
+	if total.LessThan(min) {
+		return nil, fmt.Errorf("balance %v too low to initiate shift (minimum %v)", total, min)
+	}
+	if !max.IsPositive() || total.LessThanOrEqual(max) {
+		return []decimal.Decimal{total}, nil
+	}
+
+	parts := sendCount(total, max)
+	part := total.Div(parts).RoundFloor(places)
+	if part.LessThan(min) {
+		return nil, fmt.Errorf("cannot split balance %v into shifts between %v and %v", total, min, max)
+	}
+
+	amounts := make([]decimal.Decimal, parts.IntPart())
+	remaining := total
+	for i := 0; i < len(amounts)-1; i++ {
+		amounts[i] = part
+		remaining = remaining.Sub(part)
+	}
+	// the last part picks up whatever rounding left behind
+	amounts[len(amounts)-1] = remaining
+
+	return amounts, nil
+}
+
+// runShifts takes every leg in the journal through to settlement, one after another or all at once.
+func (c *Converter) runShifts(ctx context.Context, journal *Journal, opts Opts, result *Result) error {
+	if err := c.recoverSends(ctx, journal); err != nil {
+		return &SendError{err}
+	}
+
+	var mu sync.Mutex
+	collect := func(tx *coinbase.TxResponse, shift *sideshift.ShiftResponse) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if tx != nil {
+			result.Transactions = append(result.Transactions, tx)
+		}
+		if shift != nil {
+			result.Shifts = append(result.Shifts, shift)
+			result.SettleAmount = result.SettleAmount.Add(shift.SettleAmount)
+		}
+	}
+
+	if !opts.ConcurrentShifts || len(journal.Shifts) == 1 {
+		for i, leg := range journal.Shifts {
+			tx, shift, err := c.runLeg(ctx, journal, leg, i, opts)
+			collect(tx, shift)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(journal.Shifts))
+	)
+	for i, leg := range journal.Shifts {
+		wg.Add(1)
+		go func(i int, leg *ShiftLeg) {
+			defer wg.Done()
+
+			tx, shift, err := c.runLeg(ctx, journal, leg, i, opts)
+			collect(tx, shift)
+			errs[i] = err
+		}(i, leg)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runLeg takes a single leg from wherever it got to through to settlement.
+func (c *Converter) runLeg(ctx context.Context, journal *Journal, leg *ShiftLeg, index int, opts Opts) (tx *coinbase.TxResponse, shift *sideshift.ShiftResponse, err error) {
+	logger := log.WithField("shift", index+1)
+
+	for leg.Step != StepSettled {
+		if err := ctx.Err(); err != nil {
+			return tx, shift, err
+		}
+
+		switch leg.Step {
+		case StepSplit:
+			if journal.ShiftType == ShiftTypeVariable {
+				err = c.legVariableShift(ctx, journal, leg, logger)
+			} else {
+				err = c.legQuote(ctx, journal, leg, logger)
+			}
+			if err != nil {
+				err = &ShiftError{err}
+			}
+		case StepQuoted:
+			if err = c.legShift(ctx, journal, leg, logger); err != nil {
+				err = &ShiftError{err}
+			}
+		case StepShifted:
+			if tx, err = c.legSend(ctx, journal, leg, opts, logger); err != nil {
+				err = &SendError{err}
+			}
+		case StepSent:
+			if shift, err = c.legPoll(ctx, journal, leg, index, opts, logger); err != nil {
+				err = &ShiftError{err}
+			}
+		case StepPaid:
+			if err = c.legConfirm(ctx, journal, leg, opts, logger); err != nil {
+				err = &ShiftError{err}
+			}
+		default:
+			err = fmt.Errorf("unknown shift step %v", leg.Step)
+		}
+
+		if err != nil {
+			return tx, shift, err
+		}
+	}
+
+	return tx, shift, nil
+}
+
+func (c *Converter) legQuote(ctx context.Context, journal *Journal, leg *ShiftLeg, logger log.Interface) error {
+	quote, err := c.ssClient.CreateQuote(ctx, sideshift.QuoteRequest{
+		DepositCoin:    journal.Currencies.Base,
+		SettleCoin:     journal.Currencies.Quote,
+		DepositNetwork: journal.Currencies.BaseNetwork,
+		SettleNetwork:  journal.Currencies.QuoteNetwork,
+		DepositAmount:  leg.Amount,
+	})
+	if err != nil {
+		return err
+	}
+	if err := journal.Currencies.checkNetworks(quote.DepositNetwork, quote.SettleNetwork); err != nil {
+		return err
+	}
+	logger.Infof("shift quote price is %v, expires at %v", quote.Rate, quote.ExpiresAt)
+
+	return journal.Update(func() {
+		leg.QuoteID = quote.ID
+		leg.QuoteExpiresAt = quote.ExpiresAt
+		leg.DepositAmount = quote.DepositAmount
+		leg.Step = StepQuoted
+	})
+}
+
+func (c *Converter) legShift(ctx context.Context, journal *Journal, leg *ShiftLeg, logger log.Interface) error {
+	refundAddress, err := c.getRefundAddress(ctx, journal.Currencies.Base)
+	if err != nil {
+		return err
+	}
+	logger.Infof("using %v as base refund address", refundAddress)
+
+	// checked after the refund address since creating one can be slow
+	if time.Until(leg.QuoteExpiresAt) < quoteExpiryMargin {
+		logger.Warnf("quote %v expires at %v, re-quoting", leg.QuoteID, leg.QuoteExpiresAt)
+		return c.requote(journal, leg)
+	}
+
+	logger.Infof("creating fixed shift")
+	shift, err := c.ssClient.CreateFixedShift(ctx, sideshift.FixedShiftRequest{
+		SettleAddress: journal.Address,
+		RefundAddress: refundAddress,
+		QuoteID:       leg.QuoteID,
+	})
+	if err != nil {
+		return err
+	}
+	if err := journal.Currencies.checkNetworks(shift.DepositNetwork, shift.SettleNetwork); err != nil {
+		return err
+	}
+
+	return journal.Update(func() {
+		leg.ShiftID = shift.ID
+		leg.ShiftExpiresAt = shift.ExpiresAt
+		leg.DepositAddress = shift.DepositAddress
+		leg.DepositNetwork = shift.DepositNetwork
+		leg.Step = StepShifted
+	})
+}
+
+// legVariableShift creates a shift without a quote, so the leg goes straight to sending. The rate is only fixed once
+// SideShift sees the deposit.
+func (c *Converter) legVariableShift(ctx context.Context, journal *Journal, leg *ShiftLeg, logger log.Interface) error {
+	refundAddress, err := c.getRefundAddress(ctx, journal.Currencies.Base)
+	if err != nil {
+		return err
+	}
+	logger.Infof("using %v as base refund address", refundAddress)
+
+	logger.Infof("creating variable shift")
+	shift, err := c.ssClient.CreateVariableShift(ctx, sideshift.VariableShiftRequest{
+		SettleAddress:  journal.Address,
+		RefundAddress:  refundAddress,
+		DepositCoin:    journal.Currencies.Base,
+		SettleCoin:     journal.Currencies.Quote,
+		DepositNetwork: journal.Currencies.BaseNetwork,
+		SettleNetwork:  journal.Currencies.QuoteNetwork,
+	})
+	if err != nil {
+		return err
+	}
+	if err := journal.Currencies.checkNetworks(shift.DepositNetwork, shift.SettleNetwork); err != nil {
+		return err
+	}
+	logger.Infof("variable shift %v accepts deposits between %v and %v %v", shift.ID, shift.DepositMin, shift.DepositMax, journal.Currencies.Base)
+
+	if leg.Amount.LessThan(shift.DepositMin) || leg.Amount.GreaterThan(shift.DepositMax) {
+		return fmt.Errorf("%v %v is outside the deposit range of shift %v", leg.Amount, journal.Currencies.Base, shift.ID)
+	}
+
+	return journal.Update(func() {
+		leg.ShiftID = shift.ID
+		leg.ShiftExpiresAt = shift.ExpiresAt
+		leg.DepositAddress = shift.DepositAddress
+		leg.DepositNetwork = shift.DepositNetwork
+		leg.DepositAmount = leg.Amount
+		leg.Step = StepShifted
+	})
+}
+
+func (c *Converter) legSend(ctx context.Context, journal *Journal, leg *ShiftLeg, opts Opts, logger log.Interface) (*coinbase.TxResponse, error) {
+	baseAccount, err := c.cbClient.GetAccountByCode(ctx, journal.Currencies.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	if leg.Idem != "" {
+		if time.Until(leg.ShiftExpiresAt) >= shiftExpiryMargin {
+			// an earlier attempt may have gone through, sending again with the same idem either makes the send or
+			// returns the one already made, so the shift is never paid twice
+			logger.Infof("retrying send with idem %v", leg.Idem)
+		} else {
+			// sending again could pay a shift that's about to expire, so find out whether the earlier attempt did
+			tx, err := c.findSend(ctx, baseAccount.ID, leg)
+			if err != nil {
+				return nil, err
+			}
+			if tx != nil {
+				logger.Infof("earlier send %v to shift %v went through", tx.ID, leg.ShiftID)
+				return tx, journal.Update(func() {
+					leg.TxID = tx.ID
+					leg.Step = StepSent
+				})
+			}
+			logger.Infof("earlier send with idem %v never went through", leg.Idem)
+			if err := journal.Update(func() { leg.Idem = "" }); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if leg.Idem == "" {
+		// nothing has been sent yet, so it's safe to start the leg over with a new shift
+		if time.Until(leg.ShiftExpiresAt) < shiftExpiryMargin {
+			logger.Warnf("shift %v expires at %v, too close to send safely, re-quoting", leg.ShiftID, leg.ShiftExpiresAt)
+			return nil, c.requote(journal, leg)
+		}
+
+		if err := journal.Update(func() { leg.Idem = uuid.New().String() }); err != nil {
+			return nil, err
+		}
+	}
+
+	// rounding would pay a fixed shift the wrong amount, so refuse rather than guess which way
+	if places := int32(baseAccount.Currency.Exponent); !leg.DepositAmount.Equal(leg.DepositAmount.Round(places)) {
+		return nil, fmt.Errorf("deposit amount %v has more than the %v decimal places coinbase can send", leg.DepositAmount, places)
+	}
+
+	// always name the network SideShift takes the deposit on, rather than leaving Coinbase to pick its default chain
+	network := leg.DepositNetwork
+	if network == "" {
+		// shifts made before the network was saved on the leg
+		network = journal.Currencies.BaseNetwork
+	}
+
+	logger.Infof("sending %v %v on %v to shift address %v", leg.DepositAmount, journal.Currencies.Base, network, leg.DepositAddress)
+	req := coinbase.TxRequest{
+		Type:        "send",
+		To:          leg.DepositAddress,
+		Amount:      leg.DepositAmount,
+		Currency:    journal.Currencies.Base,
+		Network:     network,
+		Idem:        leg.Idem,
+		Description: opts.SendDescription,
+		TravelRule:  opts.TravelRule,
+	}
+	tx, err := c.cbClient.CreateTransaction(ctx, baseAccount.ID, req)
+	if errors.Is(err, coinbase.ErrTwoFactorRequired) && opts.TwoFactor != nil {
+		logger.Info("coinbase needs a 2FA token to send")
+		token, err := opts.TwoFactor(ctx)
+		if err != nil {
+			return nil, c.dropIdem(journal, leg, err)
+		}
+		// typing in a token can take a while, and the refused send can't have paid the shift
+		if time.Until(leg.ShiftExpiresAt) < shiftExpiryMargin {
+			logger.Warnf("shift %v expires at %v, too close to send safely, re-quoting", leg.ShiftID, leg.ShiftExpiresAt)
+			return nil, c.requote(journal, leg)
+		}
+
+		req.TwoFactorToken = token
+		tx, err = c.cbClient.CreateTransaction(ctx, baseAccount.ID, req)
+		if err != nil {
+			return nil, c.sendFailed(journal, leg, err)
+		}
+	} else if err != nil {
+		if errors.Is(err, coinbase.ErrTwoFactorRequired) {
+			err = fmt.Errorf("%w, set up a 2FA token source and resume", err)
+		}
+		return nil, c.sendFailed(journal, leg, err)
+	}
+
+	return tx, journal.Update(func() {
+		leg.TxID = tx.ID
+		leg.Step = StepSent
+	})
+}
+
+// sendFailed keeps the leg's idem if the send that failed with err may still have gone through, and drops it if
+// Coinbase turned the send down outright. It returns err.
+func (c *Converter) sendFailed(journal *Journal, leg *ShiftLeg, err error) error {
+	if !isRefusal(err) {
+		return err
+	}
+	return c.dropIdem(journal, leg, err)
+}
+
+// dropIdem clears the leg's idem once its send is known not to have happened, so the next attempt starts over and
+// checks the shift's expiry again. It returns err.
+func (c *Converter) dropIdem(journal *Journal, leg *ShiftLeg, err error) error {
+	if uerr := journal.Update(func() { leg.Idem = "" }); uerr != nil {
+		return uerr
+	}
+	return err
+}
+
+// isRefusal reports whether Coinbase answered a send with a status that means it wasn't made.
+func isRefusal(err error) bool {
+	if errors.Is(err, coinbase.ErrTwoFactorRequired) {
+		return true
+	}
+	var statusErr *coinbase.StatusError
+	if !errors.As(err, &statusErr) {
+		// no response, or one we couldn't read
+		return false
+	}
+	switch statusErr.Code {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return statusErr.Code >= http.StatusBadRequest && statusErr.Code < http.StatusInternalServerError
+}
+
+// findSend looks through the account's recent transactions for a send to the leg's deposit address.
+func (c *Converter) findSend(ctx context.Context, account string, leg *ShiftLeg) (*coinbase.TxResponse, error) {
+	txs, err := c.cbClient.ListTransactions(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, tx := range *txs {
+		if tx.Type != "send" || tx.To.Address != leg.DepositAddress {
+			continue
+		}
+		switch tx.Status {
+		case "failed", "canceled", "expired":
+			continue
+		}
+		return &(*txs)[i], nil
+	}
+	return nil, nil
+}
+
+func (c *Converter) legPoll(ctx context.Context, journal *Journal, leg *ShiftLeg, index int, opts Opts, logger log.Interface) (*sideshift.ShiftResponse, error) {
+	logger.Info("waiting for shift completion")
+	shift, err := c.ssClient.PollShiftWithOpts(ctx, leg.ShiftID, sideshift.PollOpts{
+		Interval: opts.ShiftPollInterval,
+		Timeout:  opts.ShiftTimeout,
+		OnStatus: func(shift *sideshift.ShiftResponse) {
+			logger.Infof("shift %v is %v", shift.ID, shift.Status)
+			if opts.OnShiftStatus != nil {
+				opts.OnShiftStatus(index, shift)
+			}
+		},
+	})
+	if errors.Is(err, sideshift.ErrShiftExpired) {
+		// we've already paid so re-quoting isn't safe, SideShift will either process the late deposit or refund it
+		return nil, fmt.Errorf("shift %v expired before the deposit from transaction %v arrived: %w", leg.ShiftID, leg.TxID, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("shift paid out %v %v at a rate of %v in transaction %v", shift.SettleAmount, journal.Currencies.Quote, shift.Rate, shift.SettleHash)
+	return shift, journal.Update(func() {
+		leg.SettleHash = shift.SettleHash
+		leg.SettleAmount = shift.SettleAmount
+		leg.Step = StepPaid
+	})
+}
+
+// legConfirm waits for the payout to reach the wallet, if there's one to check, before the leg is settled.
+func (c *Converter) legConfirm(ctx context.Context, journal *Journal, leg *ShiftLeg, opts Opts, logger log.Interface) error {
+	if c.walletClient == nil || journal.Currencies.Quote != "XMR" {
+		return journal.Update(func() { leg.Step = StepSettled })
+	}
+
+	confirmations := opts.Confirmations
+	if confirmations == 0 {
+		confirmations = defaultConfirmations
+	}
+
+	logger.Infof("waiting for transaction %v to reach %v confirmations in wallet", leg.SettleHash, confirmations)
+	transfer, err := c.walletClient.WaitForTransfer(ctx, leg.SettleHash, opts.SubaddressAccount, confirmations)
+	if err != nil {
+		return err
+	}
+	if transfer.XMR().LessThan(leg.SettleAmount) {
+		return fmt.Errorf("wallet received %v XMR in transaction %v but shift settled %v", transfer.XMR(), leg.SettleHash, leg.SettleAmount)
+	}
+	logger.Infof("wallet received %v XMR with %v confirmations", transfer.XMR(), transfer.Confirmations)
+
+	return journal.Update(func() { leg.Step = StepSettled })
+}
+
+func (c *Converter) requote(journal *Journal, leg *ShiftLeg) error {
+	if leg.Requotes >= maxRequotes {
+		return fmt.Errorf("quote or shift for this leg has expired %v times, giving up", leg.Requotes+1)
+	}
+	return journal.Update(leg.requote)
+}
+
+// recoverSends moves legs whose shift already has a deposit on to polling, so a resumed run doesn't pay a shift twice.
+// A leg without an idem was never sent, since the idem is saved first and dropped when Coinbase refuses the send, and
+// one with an idem is retried with it by legSend.
+func (c *Converter) recoverSends(ctx context.Context, journal *Journal) error {
+	for _, leg := range journal.Shifts {
+		if leg.Step != StepShifted {
+			continue
+		}
+
+		shift, err := c.ssClient.GetShift(ctx, leg.ShiftID)
+		if err != nil {
+			return err
+		}
+		switch shift.Status {
+		case sideshift.StatusWaiting:
+		case sideshift.StatusExpired:
+			if leg.Idem == "" {
+				log.Warnf("shift %v expired before it was paid, re-quoting", shift.ID)
+				if err := c.requote(journal, leg); err != nil {
+					return err
+				}
+			}
+			// otherwise legSend finds out whether the earlier send went through
+		default:
+			log.Infof("shift %v already has a deposit (status %v), skipping send", shift.ID, shift.Status)
+			if err := journal.Update(func() { leg.Step = StepSent }); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package fiat2xmr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cedws/fiat2xmr/coinbase"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitAmount(t *testing.T) {
+	d := decimal.RequireFromString
+
+	amounts, err := splitAmount(d("5"), d("1"), d("10"), 8)
+	assert.Nil(t, err)
+	assert.Equal(t, "[5]", decimalStrings(amounts))
+
+	amounts, err = splitAmount(d("25"), d("1"), d("10"), 8)
+	assert.Nil(t, err)
+	assert.Equal(t, "[8.33333333 8.33333333 8.33333334]", decimalStrings(amounts))
+
+	// the total is cut to places first, so it fits in two parts and none of them are too precise to send
+	amounts, err = splitAmount(d("20.000000001"), d("1"), d("10"), 8)
+	assert.Nil(t, err)
+	assert.Equal(t, "[10 10]", decimalStrings(amounts))
+
+	amounts, err = splitAmount(d("20.000000011"), d("1"), d("10"), 8)
+	assert.Nil(t, err)
+	assert.Equal(t, "[6.66666667 6.66666667 6.66666667]", decimalStrings(amounts))
+
+	amounts, err = splitAmount(d("5.123456789"), d("1"), d("10"), 8)
+	assert.Nil(t, err)
+	assert.Equal(t, "[5.12345678]", decimalStrings(amounts))
+
+	_, err = splitAmount(d("0.5"), d("1"), d("10"), 8)
+	assert.NotNil(t, err)
+
+	_, err = splitAmount(d("11"), d("6"), d("10"), 8)
+	assert.NotNil(t, err)
+}
+
+func TestIsRefusal(t *testing.T) {
+	assert.True(t, isRefusal(fmt.Errorf("while creating transaction: %w", coinbase.ErrTwoFactorRequired)))
+	assert.True(t, isRefusal(fmt.Errorf("%w (not enough funds)", &coinbase.StatusError{Code: http.StatusBadRequest})))
+
+	// the send may have been made
+	assert.False(t, isRefusal(&coinbase.StatusError{Code: http.StatusTooManyRequests}))
+	assert.False(t, isRefusal(&coinbase.StatusError{Code: http.StatusBadGateway}))
+	assert.False(t, isRefusal(context.DeadlineExceeded))
+	assert.False(t, isRefusal(errors.New("unexpected EOF")))
+}
+
+func TestRecoverSends(t *testing.T) {
+	ss := newStubAPI(map[string][]string{
+		"GET /api/v2/shifts/waiting":   {`{"id":"waiting","status":"waiting","expiresAt":"2100-01-01T00:00:00Z"}`},
+		"GET /api/v2/shifts/paid":      {`{"id":"paid","status":"processing"}`},
+		"GET /api/v2/shifts/expired":   {`{"id":"expired","status":"expired"}`},
+		"GET /api/v2/shifts/maybesent": {`{"id":"maybesent","status":"expired"}`},
+	})
+	cnv := stubConverter(t, newStubAPI(nil), ss)
+
+	journal := NewJournal(filepath.Join(t.TempDir(), "journal.json"))
+	journal.Shifts = []*ShiftLeg{
+		{Step: StepShifted, ShiftID: "waiting"},
+		{Step: StepShifted, ShiftID: "paid", Idem: "paid"},
+		{Step: StepShifted, ShiftID: "expired"},
+		{Step: StepShifted, ShiftID: "maybesent", Idem: "maybesent"},
+	}
+	assert.Nil(t, cnv.recoverSends(context.Background(), journal))
+
+	assert.Equal(t, StepShifted, journal.Shifts[0].Step)
+	assert.Equal(t, StepSent, journal.Shifts[1].Step)
+	// never sent, so it's safe to start over
+	assert.Equal(t, StepSplit, journal.Shifts[2].Step)
+	assert.Empty(t, journal.Shifts[2].ShiftID)
+	assert.Equal(t, 1, journal.Shifts[2].Requotes)
+	// left for legSend to look up the earlier send
+	assert.Equal(t, StepShifted, journal.Shifts[3].Step)
+}
+
+func decimalStrings(amounts []decimal.Decimal) string {
+	strs := make([]string, len(amounts))
+	for i, amount := range amounts {
+		strs[i] = amount.String()
+	}
+	return "[" + strings.Join(strs, " ") + "]"
+}
@@ -0,0 +1,87 @@
+package fiat2xmr
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cedws/fiat2xmr/coinbase"
+	"github.com/cedws/fiat2xmr/sideshift"
+)
+
+// stubAPI answers requests by method and path, e.g. "GET /v2/accounts/LTC", with canned JSON bodies. Each route
+// gives its responses in order and then repeats the last one. A response may start with a status code, e.g.
+// "402 {...}", otherwise it's sent with 200. Routes without responses get a 404.
+type stubAPI struct {
+	mu        sync.Mutex
+	responses map[string][]string
+	// bodies of the requests made to each route
+	requests map[string][]string
+}
+
+func newStubAPI(responses map[string][]string) *stubAPI {
+	return &stubAPI{responses: responses, requests: make(map[string][]string)}
+}
+
+func (s *stubAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	route := r.Method + " " + r.URL.Path
+	body, _ := io.ReadAll(r.Body)
+	calls := len(s.requests[route])
+	s.requests[route] = append(s.requests[route], string(body))
+
+	responses := s.responses[route]
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("{}"))
+		return
+	}
+	if calls >= len(responses) {
+		calls = len(responses) - 1
+	}
+	res := responses[calls]
+	if code, body, ok := strings.Cut(res, " "); ok && len(code) == 3 {
+		if status, err := strconv.Atoi(code); err == nil {
+			w.WriteHeader(status)
+			res = body
+		}
+	}
+	w.Write([]byte(res))
+}
+
+func (s *stubAPI) calls(route string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[route]
+}
+
+// stubTransport serves requests to each host from a handler rather than the network.
+type stubTransport map[string]http.Handler
+
+func (s stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	handler, ok := s[req.URL.Host]
+	if !ok {
+		return nil, fmt.Errorf("no stub for host %v", req.URL.Host)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+// stubConverter returns a converter whose Coinbase and SideShift clients talk to cb and ss for the rest of the test.
+func stubConverter(t *testing.T, cb, ss http.Handler) *Converter {
+	old := http.DefaultTransport
+	http.DefaultTransport = stubTransport{"api.coinbase.com": cb, "sideshift.ai": ss}
+	t.Cleanup(func() { http.DefaultTransport = old })
+
+	return NewConverter(sideshift.NewClient("secret"), coinbase.NewClient("key", "secret"))
+}
@@ -0,0 +1,99 @@
+package fiat2xmr
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/cedws/fiat2xmr/monero"
+)
+
+// subaddressState records the next unused subaddress index in each account, so every conversion settles to an
+// address that hasn't been used before.
+type subaddressState struct {
+	path string
+
+	Next map[uint32]uint32 `json:"next"`
+}
+
+func openSubaddressState(path string) (*subaddressState, error) {
+	state := &subaddressState{path: path, Next: make(map[uint32]uint32)}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(state); err != nil {
+		return nil, err
+	}
+	if state.Next == nil {
+		state.Next = make(map[uint32]uint32)
+	}
+
+	return state, nil
+}
+
+// next returns the next unused minor index in account. Minor index 0 of account 0 is the primary address, so it's
+// skipped.
+func (s *subaddressState) next(account uint32) uint32 {
+	minor := s.Next[account]
+	if account == 0 && minor == 0 {
+		minor = 1
+	}
+	return minor
+}
+
+// use records minor as used in account and persists the state.
+func (s *subaddressState) use(account, minor uint32) error {
+	s.Next[account] = minor + 1
+	return saveJSON(s.path, s)
+}
+
+// nextSubaddress derives the next unused subaddress of the wallet with primary address opts.Address and private view
+// key opts.ViewKey. The returned function marks it as used.
+func nextSubaddress(opts Opts) (string, func() error, error) {
+	primary, err := monero.ParseAddress(opts.Address)
+	if err != nil {
+		return "", nil, err
+	}
+	var viewKey [32]byte
+	if err := decodeKey(viewKey[:], opts.ViewKey); err != nil {
+		return "", nil, fmt.Errorf("invalid private view key: %w", err)
+	}
+
+	state, err := openSubaddressState(opts.SubaddressStatePath)
+	if err != nil {
+		return "", nil, err
+	}
+	minor := state.next(opts.SubaddressAccount)
+
+	address, err := primary.Subaddress(viewKey, opts.SubaddressAccount, minor)
+	if err != nil {
+		return "", nil, err
+	}
+
+	use := func() error {
+		return state.use(opts.SubaddressAccount, minor)
+	}
+	return address.String(), use, nil
+}
+
+func decodeKey(dst []byte, encoded string) error {
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+	if len(decoded) != len(dst) {
+		return fmt.Errorf("must be %v bytes, got %v", len(dst), len(decoded))
+	}
+	copy(dst, decoded)
+	return nil
+}
@@ -3,10 +3,13 @@ module github.com/cedws/fiat2xmr
 go 1.19
 
 require (
+	filippo.io/edwards25519 v1.0.0
 	github.com/apex/log v1.9.0
 	github.com/google/uuid v1.3.0
+	github.com/shopspring/decimal v1.3.1
 	github.com/spf13/cobra v1.6.1
 	github.com/stretchr/testify v1.8.1
+	golang.org/x/crypto v0.17.0
 )
 
 require (
@@ -15,5 +18,6 @@ require (
 	github.com/pkg/errors v0.8.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/sys v0.15.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
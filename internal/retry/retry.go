@@ -0,0 +1,121 @@
+// Package retry sends HTTP requests again when they fail in ways that are worth waiting out, like rate limits and
+// server errors.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Policy struct {
+	// Attempts including the first.
+	MaxAttempts int
+	// Backoff before the second attempt, doubling for each one after.
+	BaseDelay time.Duration
+	// Longest to back off for. A server asking for a longer wait is given up on rather than waited for.
+	MaxDelay time.Duration
+	// Reads how long the server asked to wait from a response's headers. Defaults to RetryAfter.
+	After func(header http.Header, now time.Time) (time.Duration, bool)
+}
+
+func Default() Policy {
+	return Policy{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// Do calls send until it succeeds or it isn't worth trying again. Only set retry for requests that are safe to send
+// more than once. The last response or error is returned as is.
+func (p Policy) Do(ctx context.Context, retry bool, send func() (*http.Response, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := send()
+		if !retry || attempt+1 >= p.MaxAttempts || !ShouldRetry(ctx, res, err) {
+			return res, err
+		}
+
+		delay, ok := p.Delay(attempt, res, time.Now())
+		if !ok {
+			return res, err
+		}
+		if res != nil {
+			// drain body so TCP conn can be reused
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		if err := Sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ShouldRetry reports whether a request that got res or err is worth trying again.
+func ShouldRetry(ctx context.Context, res *http.Response, err error) bool {
+	if err != nil {
+		// the request never got a response, unless we gave up on it
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+}
+
+// Delay works out how long to wait after the given attempt, preferring whatever the response asks for. It reports
+// false if the response asks for longer than MaxDelay.
+func (p Policy) Delay(attempt int, res *http.Response, now time.Time) (time.Duration, bool) {
+	if res != nil {
+		after := p.After
+		if after == nil {
+			after = RetryAfter
+		}
+		if delay, ok := after(res.Header, now); ok {
+			return delay, delay <= p.MaxDelay
+		}
+	}
+
+	// exponential backoff with jitter, so clients that failed together don't retry together
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)), true
+}
+
+// RetryAfter reads the delay from a Retry-After header, given either in seconds or as a date.
+func RetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return NonNegative(at.Sub(now)), true
+	}
+	return 0, false
+}
+
+func NonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Sleep waits for d or until ctx is done, whichever comes first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
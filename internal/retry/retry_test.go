@@ -0,0 +1,70 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	delay, ok := RetryAfter(http.Header{"Retry-After": {"5"}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, delay)
+
+	delay, ok = RetryAfter(http.Header{"Retry-After": {now.Add(time.Minute).UTC().Format(http.TimeFormat)}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, delay)
+
+	delay, ok = RetryAfter(http.Header{"Retry-After": {now.Add(-time.Minute).UTC().Format(http.TimeFormat)}}, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), delay)
+
+	_, ok = RetryAfter(http.Header{}, now)
+	assert.False(t, ok)
+}
+
+func TestDelay(t *testing.T) {
+	policy := Default()
+	now := time.Unix(1000, 0)
+
+	res := &http.Response{Header: http.Header{"Retry-After": {"5"}}}
+	delay, ok := policy.Delay(0, res, now)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, delay)
+
+	// longer than we're willing to wait
+	res = &http.Response{Header: http.Header{"Retry-After": {"3600"}}}
+	_, ok = policy.Delay(0, res, now)
+	assert.False(t, ok)
+
+	for attempt := 0; attempt < 10; attempt++ {
+		delay, ok := policy.Delay(attempt, nil, now)
+		assert.True(t, ok)
+		assert.LessOrEqual(t, delay, policy.MaxDelay)
+	}
+}
+
+func TestDoGivesUpOnLongRetryAfter(t *testing.T) {
+	calls := 0
+	res, err := Default().Do(context.Background(), true, func() (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"3600"}}}, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal(t, 1, calls)
+}
+
+func TestShouldRetry(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, ShouldRetry(ctx, nil, errors.New("connection reset")))
+	assert.False(t, ShouldRetry(ctx, nil, context.Canceled))
+	assert.True(t, ShouldRetry(ctx, &http.Response{StatusCode: http.StatusBadGateway}, nil))
+	assert.False(t, ShouldRetry(ctx, &http.Response{StatusCode: http.StatusBadRequest}, nil))
+}
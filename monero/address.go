@@ -0,0 +1,177 @@
+package monero
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/sha3"
+)
+
+type Network int
+
+const (
+	Mainnet Network = iota
+	Stagenet
+	Testnet
+)
+
+func (n Network) String() string {
+	switch n {
+	case Mainnet:
+		return "mainnet"
+	case Stagenet:
+		return "stagenet"
+	case Testnet:
+		return "testnet"
+	default:
+		return fmt.Sprintf("Network(%d)", int(n))
+	}
+}
+
+type AddressType int
+
+const (
+	Standard AddressType = iota
+	Integrated
+	Subaddress
+)
+
+func (t AddressType) String() string {
+	switch t {
+	case Standard:
+		return "standard"
+	case Integrated:
+		return "integrated"
+	case Subaddress:
+		return "subaddress"
+	default:
+		return fmt.Sprintf("AddressType(%d)", int(t))
+	}
+}
+
+type prefix struct {
+	network     Network
+	addressType AddressType
+}
+
+// Network bytes from cryptonote_config.h.
+var prefixes = map[uint64]prefix{
+	18: {Mainnet, Standard},
+	19: {Mainnet, Integrated},
+	42: {Mainnet, Subaddress},
+	24: {Stagenet, Standard},
+	25: {Stagenet, Integrated},
+	36: {Stagenet, Subaddress},
+	53: {Testnet, Standard},
+	54: {Testnet, Integrated},
+	63: {Testnet, Subaddress},
+}
+
+const (
+	keySize       = 32
+	paymentIDSize = 8
+	checksumSize  = 4
+)
+
+var (
+	ErrInvalidAddress   = errors.New("invalid monero address")
+	ErrInvalidChecksum  = errors.New("invalid monero address checksum")
+	ErrInvalidPaymentID = errors.New("invalid monero payment ID")
+)
+
+type Address struct {
+	Network  Network
+	Type     AddressType
+	SpendKey [keySize]byte
+	ViewKey  [keySize]byte
+	// Only set for integrated addresses.
+	PaymentID []byte
+}
+
+// ParseAddress decodes a Monero address and verifies its network byte and checksum.
+func ParseAddress(encoded string) (*Address, error) {
+	decoded, err := DecodeBase58(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+
+	tag, n := binary.Uvarint(decoded)
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: bad network byte", ErrInvalidAddress)
+	}
+	p, ok := prefixes[tag]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown network byte %v", ErrInvalidAddress, tag)
+	}
+
+	expectedSize := n + 2*keySize + checksumSize
+	if p.addressType == Integrated {
+		expectedSize += paymentIDSize
+	}
+	if len(decoded) != expectedSize {
+		return nil, fmt.Errorf("%w: wrong length for %v %v address", ErrInvalidAddress, p.network, p.addressType)
+	}
+
+	body, checksum := decoded[:len(decoded)-checksumSize], decoded[len(decoded)-checksumSize:]
+	if !bytes.Equal(checksum, keccak256(body)[:checksumSize]) {
+		return nil, ErrInvalidChecksum
+	}
+
+	address := Address{
+		Network: p.network,
+		Type:    p.addressType,
+	}
+	copy(address.SpendKey[:], body[n:])
+	copy(address.ViewKey[:], body[n+keySize:])
+	if p.addressType == Integrated {
+		address.PaymentID = append([]byte(nil), body[n+2*keySize:]...)
+	}
+
+	return &address, nil
+}
+
+// Integrated returns the integrated address combining a standard address with an 8 byte payment ID.
+func (a *Address) Integrated(paymentID []byte) (*Address, error) {
+	if a.Type != Standard {
+		return nil, fmt.Errorf("%w: only standard addresses can be integrated, not %v", ErrInvalidAddress, a.Type)
+	}
+	if len(paymentID) != paymentIDSize {
+		return nil, fmt.Errorf("%w: must be %v bytes", ErrInvalidPaymentID, paymentIDSize)
+	}
+
+	integrated := *a
+	integrated.Type = Integrated
+	integrated.PaymentID = append([]byte(nil), paymentID...)
+
+	return &integrated, nil
+}
+
+func (a *Address) String() string {
+	var tag uint64
+	for t, p := range prefixes {
+		if p.network == a.Network && p.addressType == a.Type {
+			tag = t
+			break
+		}
+	}
+
+	body := binary.AppendUvarint(nil, tag)
+	body = append(body, a.SpendKey[:]...)
+	body = append(body, a.ViewKey[:]...)
+	if a.Type == Integrated {
+		body = append(body, a.PaymentID...)
+	}
+	body = append(body, keccak256(body)[:checksumSize]...)
+
+	return EncodeBase58(body)
+}
+
+func keccak256(data ...[]byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		hash.Write(d)
+	}
+	return hash.Sum(nil)
+}
@@ -0,0 +1,58 @@
+package monero
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const donationAddress = "44AFFq5kSiGBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVGQBEP3A"
+
+func TestParseAddress(t *testing.T) {
+	address, err := ParseAddress(donationAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, Mainnet, address.Network)
+	assert.Equal(t, Standard, address.Type)
+	assert.Nil(t, address.PaymentID)
+	assert.Equal(t, donationAddress, address.String())
+}
+
+func TestParseAddressChecksum(t *testing.T) {
+	_, err := ParseAddress(donationAddress[:len(donationAddress)-1] + "B")
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+}
+
+func TestParseAddressInvalid(t *testing.T) {
+	for _, encoded := range []string{
+		"",
+		"44AFFq5kSiGBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVGQBEP3",
+		"0AFFq5kSiGBoZ4NMDwYtN18obc8AemS33DBLWs3H7otXft3XjrpDtQGv7SqSsaBYBb98uNbr2VBBEt7f2wfn3RVGQBEP3A",
+		"1111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111",
+	} {
+		_, err := ParseAddress(encoded)
+		assert.NotNil(t, err, encoded)
+	}
+}
+
+func TestIntegratedAddressRoundTrip(t *testing.T) {
+	address, err := ParseAddress(donationAddress)
+	assert.Nil(t, err)
+
+	paymentID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	integrated, err := address.Integrated(paymentID)
+	assert.Nil(t, err)
+	encoded := integrated.String()
+	assert.Len(t, encoded, 106)
+
+	parsed, err := ParseAddress(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, Integrated, parsed.Type)
+	assert.Equal(t, address.SpendKey, parsed.SpendKey)
+	assert.Equal(t, address.ViewKey, parsed.ViewKey)
+	assert.Equal(t, paymentID, parsed.PaymentID)
+
+	_, err = parsed.Integrated(paymentID)
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+	_, err = address.Integrated([]byte{1, 2, 3})
+	assert.ErrorIs(t, err, ErrInvalidPaymentID)
+}
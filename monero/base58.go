@@ -0,0 +1,105 @@
+package monero
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Monero's base58 differs from Bitcoin's by encoding in fixed 8 byte blocks of 11 characters, so the length of an
+// encoded string is always predictable.
+const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+const (
+	fullBlockSize        = 8
+	fullEncodedBlockSize = 11
+)
+
+// encodedBlockSizes maps the size of a block in bytes to the size of the encoded block.
+var encodedBlockSizes = []int{0, 2, 3, 5, 6, 7, 9, 10, 11}
+
+var (
+	ErrInvalidBase58       = errors.New("invalid base58")
+	ErrInvalidBase58Length = errors.New("invalid base58 length")
+)
+
+func EncodeBase58(data []byte) string {
+	var sb strings.Builder
+
+	for len(data) > 0 {
+		size := fullBlockSize
+		if len(data) < size {
+			size = len(data)
+		}
+		sb.WriteString(encodeBlock(data[:size]))
+		data = data[size:]
+	}
+
+	return sb.String()
+}
+
+func DecodeBase58(encoded string) ([]byte, error) {
+	var decoded []byte
+
+	for len(encoded) > 0 {
+		size := fullEncodedBlockSize
+		if len(encoded) < size {
+			size = len(encoded)
+		}
+
+		block, err := decodeBlock(encoded[:size])
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, block...)
+		encoded = encoded[size:]
+	}
+
+	return decoded, nil
+}
+
+func encodeBlock(block []byte) string {
+	num := new(big.Int).SetBytes(block)
+	base := big.NewInt(int64(len(alphabet)))
+	rem := new(big.Int)
+
+	encoded := make([]byte, encodedBlockSizes[len(block)])
+	for i := len(encoded) - 1; i >= 0; i-- {
+		num.DivMod(num, base, rem)
+		encoded[i] = alphabet[rem.Int64()]
+	}
+
+	return string(encoded)
+}
+
+func decodeBlock(block string) ([]byte, error) {
+	size := -1
+	for i, encodedSize := range encodedBlockSizes {
+		if encodedSize == len(block) {
+			size = i
+			break
+		}
+	}
+	if size <= 0 {
+		return nil, ErrInvalidBase58Length
+	}
+
+	num := new(big.Int)
+	base := big.NewInt(int64(len(alphabet)))
+	for _, c := range []byte(block) {
+		digit := strings.IndexByte(alphabet, c)
+		if digit < 0 {
+			return nil, ErrInvalidBase58
+		}
+		num.Mul(num, base)
+		num.Add(num, big.NewInt(int64(digit)))
+	}
+
+	// a block that decodes to more bytes than it should have held isn't valid
+	if num.BitLen() > size*8 {
+		return nil, ErrInvalidBase58
+	}
+
+	decoded := make([]byte, size)
+	return num.FillBytes(decoded), nil
+}
@@ -0,0 +1,69 @@
+package monero
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"filippo.io/edwards25519"
+)
+
+var ErrViewKeyMismatch = errors.New("private view key does not belong to monero address")
+
+// Subaddress derives the subaddress at index (major, minor) of a wallet from its primary address and private view key.
+// Index (0, 0) is the primary address itself. The view key is checked against the address first, since subaddresses
+// derived from the wrong key look valid but are never scanned by the wallet.
+func (a *Address) Subaddress(viewKey [keySize]byte, major, minor uint32) (*Address, error) {
+	if a.Type != Standard {
+		return nil, fmt.Errorf("%w: subaddresses can only be derived from a primary address, not %v", ErrInvalidAddress, a.Type)
+	}
+
+	spend, err := edwards25519.NewIdentityPoint().SetBytes(a.SpendKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid public spend key: %v", ErrInvalidAddress, err)
+	}
+	view, err := edwards25519.NewScalar().SetCanonicalBytes(viewKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid private view key: %w", err)
+	}
+	if publicView := edwards25519.NewIdentityPoint().ScalarBaseMult(view).Bytes(); string(publicView) != string(a.ViewKey[:]) {
+		return nil, ErrViewKeyMismatch
+	}
+
+	if major == 0 && minor == 0 {
+		primary := *a
+		return &primary, nil
+	}
+
+	// D = B + Hs("SubAddr\0" || a || major || minor)G, C = aD
+	data := append([]byte("SubAddr\x00"), viewKey[:]...)
+	data = binary.LittleEndian.AppendUint32(data, major)
+	data = binary.LittleEndian.AppendUint32(data, minor)
+	m := hashToScalar(data)
+
+	d := edwards25519.NewIdentityPoint().ScalarBaseMult(m)
+	d.Add(d, spend)
+	c := edwards25519.NewIdentityPoint().ScalarMult(view, d)
+
+	address := Address{
+		Network: a.Network,
+		Type:    Subaddress,
+	}
+	copy(address.SpendKey[:], d.Bytes())
+	copy(address.ViewKey[:], c.Bytes())
+
+	return &address, nil
+}
+
+// hashToScalar is Monero's Hs, Keccak-256 reduced modulo the group order.
+func hashToScalar(data []byte) *edwards25519.Scalar {
+	wide := make([]byte, 64)
+	copy(wide, keccak256(data))
+
+	s, err := edwards25519.NewScalar().SetUniformBytes(wide)
+	if err != nil {
+		// only possible if wide isn't 64 bytes
+		panic(err)
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package monero
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"filippo.io/edwards25519"
+	"github.com/stretchr/testify/assert"
+)
+
+// Keys and subaddresses from go-monero's test suite, with (0, 1) and the primary address worked out with the same
+// library, so none of them depend on this package.
+const (
+	vectorPrimary = "48ukkZtBSBRL8iva7k3p2sBVMLWTfNwsTbW1aVh5M84g21muDCssvCHTpoZCaSc6rq8M9QLZ3sQMrMn1bq2RD2anGnyHhtq"
+	vectorViewKey = "ac413c16b815899b69393d72086fa86d31e8e352895606180c4c8fadd707450a"
+)
+
+func vectorKeys(t *testing.T) (*Address, [keySize]byte) {
+	primary, err := ParseAddress(vectorPrimary)
+	assert.Nil(t, err)
+
+	var viewKey [keySize]byte
+	decoded, err := hex.DecodeString(vectorViewKey)
+	assert.Nil(t, err)
+	copy(viewKey[:], decoded)
+
+	return primary, viewKey
+}
+
+func TestSubaddressVectors(t *testing.T) {
+	primary, viewKey := vectorKeys(t)
+
+	for _, tc := range []struct {
+		major, minor uint32
+		expected     string
+	}{
+		{0, 0, vectorPrimary},
+		{0, 1, "84nvgV2eTnG1vAKbg87MnbfjWrSY3eH3s2eykmggk549C8zdNk4PPD7iv7BPfPsnoH9NjXaRhjC19FY6PBmXZUtoG5SEiY7"},
+		{1, 0, "87BTvS4grAXSrwgzonu3N8Tm7N6W29UGAcd3GLumriVYiCJrUbsyPGWQoA92FZ6MgKWStiZjhS6o9Eeh6yinHH5NAgE9CUe"},
+		{2, 3, "87aJx3x1cd56PS9JZdY4rzFSWcjvh274ERV1LYmFzzTwYFbfzWLRfgxTm8zBCPxPmoCpEnmHgDAn3dNAi1zRchNv8zdeQ1i"},
+	} {
+		sub, err := primary.Subaddress(viewKey, tc.major, tc.minor)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, sub.String(), "subaddress (%v, %v)", tc.major, tc.minor)
+	}
+}
+
+func TestSubaddressSpendKey(t *testing.T) {
+	spendSecret := hashToScalar([]byte("spend"))
+	viewSecret := hashToScalar([]byte("view"))
+
+	primary := Address{Network: Mainnet, Type: Standard}
+	copy(primary.SpendKey[:], edwards25519.NewIdentityPoint().ScalarBaseMult(spendSecret).Bytes())
+	copy(primary.ViewKey[:], edwards25519.NewIdentityPoint().ScalarBaseMult(viewSecret).Bytes())
+	var viewKey [keySize]byte
+	copy(viewKey[:], viewSecret.Bytes())
+
+	sub, err := primary.Subaddress(viewKey, 0, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, Subaddress, sub.Type)
+
+	// the wallet spends from a subaddress with b + m, so D must equal (b + m)G
+	data := append([]byte("SubAddr\x00"), viewKey[:]...)
+	data = append(data, 0, 0, 0, 0, 1, 0, 0, 0)
+	secret := edwards25519.NewScalar().Add(spendSecret, hashToScalar(data))
+	assert.Equal(t, edwards25519.NewIdentityPoint().ScalarBaseMult(secret).Bytes(), sub.SpendKey[:])
+
+	next, err := primary.Subaddress(viewKey, 0, 2)
+	assert.Nil(t, err)
+	assert.False(t, bytes.Equal(sub.SpendKey[:], next.SpendKey[:]))
+}
+
+func TestSubaddressInvalidKeys(t *testing.T) {
+	primary, viewKey := vectorKeys(t)
+
+	// one wrong byte gives a valid scalar that belongs to some other wallet
+	wrongKey := viewKey
+	wrongKey[0] ^= 1
+	_, err := primary.Subaddress(wrongKey, 0, 1)
+	assert.ErrorIs(t, err, ErrViewKeyMismatch)
+
+	badScalar := [keySize]byte{31: 0xff}
+	_, err = primary.Subaddress(badScalar, 0, 1)
+	assert.NotNil(t, err)
+
+	// there's no point with y = 2
+	badPoint := *primary
+	badPoint.SpendKey = [keySize]byte{2}
+	_, err = badPoint.Subaddress(viewKey, 0, 1)
+	assert.NotNil(t, err)
+
+	sub, err := primary.Subaddress(viewKey, 0, 1)
+	assert.Nil(t, err)
+	_, err = sub.Subaddress(viewKey, 0, 1)
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+}
@@ -0,0 +1,57 @@
+package sideshift
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrShiftExpired = errors.New("shift expired")
+
+// StatusError is returned when SideShift responds with a non-2xx status.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("bad status code %v: %v", e.Code, e.Message)
+	}
+	return fmt.Sprintf("bad status code %v", e.Code)
+}
+
+// RefundedError is returned when SideShift refunded the deposit instead of settling the shift.
+type RefundedError struct {
+	Shift  *ShiftResponse
+	TxHash string
+	Amount decimal.Decimal
+}
+
+func (e *RefundedError) Error() string {
+	return fmt.Sprintf("shift %v was refunded %v %v in transaction %v", e.Shift.ID, e.Amount, e.Shift.DepositCoin, e.TxHash)
+}
+
+// MultipleDepositsError is returned when more than one deposit was made to a shift, once every deposit has either
+// settled or been refunded. Each deposit is handled separately so the shift as a whole has no single outcome.
+type MultipleDepositsError struct {
+	Shift    *ShiftResponse
+	Deposits []Deposit
+}
+
+func (e *MultipleDepositsError) Error() string {
+	deposits := make([]string, 0, len(e.Deposits))
+	for _, deposit := range e.Deposits {
+		switch deposit.Status {
+		case StatusSettled:
+			deposits = append(deposits, fmt.Sprintf("%v %v settled as %v %v in %v", deposit.DepositAmount, e.Shift.DepositCoin, deposit.SettleAmount, e.Shift.SettleCoin, deposit.SettleHash))
+		case StatusRefunded:
+			deposits = append(deposits, fmt.Sprintf("%v %v refunded in %v", deposit.DepositAmount, e.Shift.DepositCoin, deposit.RefundHash))
+		default:
+			deposits = append(deposits, fmt.Sprintf("%v %v %v", deposit.DepositAmount, e.Shift.DepositCoin, deposit.Status))
+		}
+	}
+	return fmt.Sprintf("shift %v received %v deposits: %v", e.Shift.ID, len(e.Deposits), strings.Join(deposits, "; "))
+}
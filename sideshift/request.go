@@ -1,14 +1,51 @@
 package sideshift
 
+import (
+	"encoding/json"
+
+	"github.com/shopspring/decimal"
+)
+
 type FixedShiftRequest struct {
 	SettleAddress string `json:"settleAddress,omitempty"`
 	QuoteID       string `json:"quoteId,omitempty"`
 	RefundAddress string `json:"refundAddress,omitempty"`
 }
 
+type VariableShiftRequest struct {
+	SettleAddress  string `json:"settleAddress,omitempty"`
+	RefundAddress  string `json:"refundAddress,omitempty"`
+	DepositCoin    string `json:"depositCoin,omitempty"`
+	SettleCoin     string `json:"settleCoin,omitempty"`
+	DepositNetwork string `json:"depositNetwork,omitempty"`
+	SettleNetwork  string `json:"settleNetwork,omitempty"`
+}
+
+// QuoteRequest may leave the networks empty to use each coin's default. A fixed shift uses the networks of its quote.
+// Either the deposit or the settle amount is given, whichever is left zero isn't sent.
 type QuoteRequest struct {
-	DepositCoin   string  `json:"depositCoin,omitempty"`
-	SettleCoin    string  `json:"settleCoin,omitempty"`
-	DepositAmount float64 `json:"depositAmount,omitempty,string"`
-	SettleAmount  float64 `json:"settleAmount,omitempty,string"`
+	DepositCoin    string          `json:"depositCoin,omitempty"`
+	SettleCoin     string          `json:"settleCoin,omitempty"`
+	DepositNetwork string          `json:"depositNetwork,omitempty"`
+	SettleNetwork  string          `json:"settleNetwork,omitempty"`
+	DepositAmount  decimal.Decimal `json:"depositAmount"`
+	SettleAmount   decimal.Decimal `json:"settleAmount"`
+}
+
+func (q QuoteRequest) MarshalJSON() ([]byte, error) {
+	// a distinct type so this method isn't called again
+	type quoteRequest QuoteRequest
+	var amounts struct {
+		quoteRequest
+		DepositAmount *decimal.Decimal `json:"depositAmount,omitempty"`
+		SettleAmount  *decimal.Decimal `json:"settleAmount,omitempty"`
+	}
+	amounts.quoteRequest = quoteRequest(q)
+	if !q.DepositAmount.IsZero() {
+		amounts.DepositAmount = &q.DepositAmount
+	}
+	if !q.SettleAmount.IsZero() {
+		amounts.SettleAmount = &q.SettleAmount
+	}
+	return json.Marshal(amounts)
 }
@@ -1,19 +1,23 @@
 package sideshift
 
-import "time"
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type PermissionsResponse struct {
 	CreateShift bool `json:"createShift"`
 }
 
 type PairResponse struct {
-	Min            float64 `json:"min,omitempty,string"`
-	Max            float64 `json:"max,omitempty,string"`
-	Rate           float64 `json:"rate,omitempty,string"`
-	DepositCoin    string  `json:"depositCoin,omitempty"`
-	SettleCoin     string  `json:"settleCoin,omitempty"`
-	DepositNetwork string  `json:"depositNetwork,omitempty"`
-	SettleNetwork  string  `json:"settleNetwork,omitempty"`
+	Min            decimal.Decimal `json:"min"`
+	Max            decimal.Decimal `json:"max"`
+	Rate           decimal.Decimal `json:"rate"`
+	DepositCoin    string          `json:"depositCoin,omitempty"`
+	SettleCoin     string          `json:"settleCoin,omitempty"`
+	DepositNetwork string          `json:"depositNetwork,omitempty"`
+	SettleNetwork  string          `json:"settleNetwork,omitempty"`
 }
 
 type FixedShiftResponse struct {
@@ -38,37 +42,74 @@ type FixedShiftResponse struct {
 	Rate           string    `json:"rate,omitempty"`
 }
 
+type VariableShiftResponse struct {
+	ID             string          `json:"id,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt,omitempty"`
+	DepositCoin    string          `json:"depositCoin,omitempty"`
+	SettleCoin     string          `json:"settleCoin,omitempty"`
+	DepositNetwork string          `json:"depositNetwork,omitempty"`
+	SettleNetwork  string          `json:"settleNetwork,omitempty"`
+	DepositAddress string          `json:"depositAddress,omitempty"`
+	SettleAddress  string          `json:"settleAddress,omitempty"`
+	DepositMin     decimal.Decimal `json:"depositMin"`
+	DepositMax     decimal.Decimal `json:"depositMax"`
+	RefundAddress  string          `json:"refundAddress,omitempty"`
+	Type           string          `json:"type,omitempty"`
+	ExpiresAt      time.Time       `json:"expiresAt,omitempty"`
+	Status         string          `json:"status,omitempty"`
+	UpdatedAt      time.Time       `json:"updatedAt,omitempty"`
+}
+
 type ShiftResponse struct {
-	ID                string    `json:"id,omitempty"`
-	CreatedAt         time.Time `json:"createdAt,omitempty"`
-	DepositCoin       string    `json:"depositCoin,omitempty"`
-	SettleCoin        string    `json:"settleCoin,omitempty"`
-	DepositNetwork    string    `json:"depositNetwork,omitempty"`
-	SettleNetwork     string    `json:"settleNetwork,omitempty"`
-	DepositAddress    string    `json:"depositAddress,omitempty"`
-	SettleAddress     string    `json:"settleAddress,omitempty"`
-	DepositMin        float64   `json:"depositMin,omitempty,string"`
-	DepositMax        float64   `json:"depositMax,omitempty,string"`
-	Type              string    `json:"type,omitempty"`
-	ExpiresAt         time.Time `json:"expiresAt,omitempty"`
-	Status            string    `json:"status,omitempty"`
-	UpdatedAt         time.Time `json:"updatedAt,omitempty"`
-	DepositHash       string    `json:"depositHash,omitempty"`
-	SettleHash        string    `json:"settleHash,omitempty"`
-	DepositReceivedAt time.Time `json:"depositReceivedAt,omitempty"`
-	Rate              float64   `json:"rate,omitempty,string"`
+	ID                string          `json:"id,omitempty"`
+	CreatedAt         time.Time       `json:"createdAt,omitempty"`
+	DepositCoin       string          `json:"depositCoin,omitempty"`
+	SettleCoin        string          `json:"settleCoin,omitempty"`
+	DepositNetwork    string          `json:"depositNetwork,omitempty"`
+	SettleNetwork     string          `json:"settleNetwork,omitempty"`
+	DepositAddress    string          `json:"depositAddress,omitempty"`
+	SettleAddress     string          `json:"settleAddress,omitempty"`
+	DepositMin        decimal.Decimal `json:"depositMin"`
+	DepositMax        decimal.Decimal `json:"depositMax"`
+	DepositAmount     decimal.Decimal `json:"depositAmount"`
+	SettleAmount      decimal.Decimal `json:"settleAmount"`
+	Type              string          `json:"type,omitempty"`
+	ExpiresAt         time.Time       `json:"expiresAt,omitempty"`
+	Status            string          `json:"status,omitempty"`
+	UpdatedAt         time.Time       `json:"updatedAt,omitempty"`
+	DepositHash       string          `json:"depositHash,omitempty"`
+	SettleHash        string          `json:"settleHash,omitempty"`
+	DepositReceivedAt time.Time       `json:"depositReceivedAt,omitempty"`
+	Rate              decimal.Decimal `json:"rate"`
+	RefundAddress     string          `json:"refundAddress,omitempty"`
+	RefundHash        string          `json:"refundHash,omitempty"`
+	RefundAmount      decimal.Decimal `json:"refundAmount"`
+	// Only set when the status is multiple, one for each deposit made to the shift.
+	Deposits []Deposit `json:"deposits,omitempty"`
+}
+
+type Deposit struct {
+	Status            string          `json:"status,omitempty"`
+	UpdatedAt         time.Time       `json:"updatedAt,omitempty"`
+	DepositHash       string          `json:"depositHash,omitempty"`
+	SettleHash        string          `json:"settleHash,omitempty"`
+	RefundHash        string          `json:"refundHash,omitempty"`
+	DepositReceivedAt time.Time       `json:"depositReceivedAt,omitempty"`
+	DepositAmount     decimal.Decimal `json:"depositAmount"`
+	SettleAmount      decimal.Decimal `json:"settleAmount"`
+	RefundAmount      decimal.Decimal `json:"refundAmount"`
 }
 
 type QuoteResponse struct {
-	ID             string    `json:"id,omitempty"`
-	CreatedAt      time.Time `json:"createdAt,omitempty"`
-	DepositCoin    string    `json:"depositCoin,omitempty"`
-	SettleCoin     string    `json:"settleCoin,omitempty"`
-	DepositNetwork string    `json:"depositNetwork,omitempty"`
-	SettleNetwork  string    `json:"settleNetwork,omitempty"`
-	ExpiresAt      time.Time `json:"expiresAt,omitempty"`
-	DepositAmount  float64   `json:"depositAmount,omitempty,string"`
-	SettleAmount   float64   `json:"settleAmount,omitempty,string"`
-	Rate           float64   `json:"rate,omitempty,string"`
-	AffiliateID    string    `json:"affiliateId,omitempty"`
+	ID             string          `json:"id,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt,omitempty"`
+	DepositCoin    string          `json:"depositCoin,omitempty"`
+	SettleCoin     string          `json:"settleCoin,omitempty"`
+	DepositNetwork string          `json:"depositNetwork,omitempty"`
+	SettleNetwork  string          `json:"settleNetwork,omitempty"`
+	ExpiresAt      time.Time       `json:"expiresAt,omitempty"`
+	DepositAmount  decimal.Decimal `json:"depositAmount"`
+	SettleAmount   decimal.Decimal `json:"settleAmount"`
+	Rate           decimal.Decimal `json:"rate"`
+	AffiliateID    string          `json:"affiliateId,omitempty"`
 }
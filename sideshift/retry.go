@@ -0,0 +1,6 @@
+package sideshift
+
+import "github.com/cedws/fiat2xmr/internal/retry"
+
+// Overridden in tests.
+var retryPolicy = retry.Default()
@@ -1,7 +1,10 @@
 package sideshift
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -20,6 +23,12 @@ const (
 	StatusRefunding  = "refunding"
 	StatusRefunded   = "refunded"
 	StatusMultiple   = "multiple"
+	StatusExpired    = "expired"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+	defaultMaxBackoff   = 5 * time.Minute
 )
 
 // Overridden in tests.
@@ -27,18 +36,12 @@ var (
 	sideshiftV2 = "https://sideshift.ai/api/v2"
 )
 
-func request[T any, U any](c *Client, method, endpoint string, body *T) (*U, error) {
-	bodyReader, bodyWriter := io.Pipe()
-
+func request[T any, U any](ctx context.Context, c *Client, method, endpoint string, body *T) (*U, error) {
+	var encoded bytes.Buffer
 	if body != nil && method != http.MethodGet {
-		go func() error {
-			if err := json.NewEncoder(bodyWriter).Encode(body); err != nil {
-				return bodyWriter.CloseWithError(err)
-			}
-			return bodyWriter.Close()
-		}()
-	} else {
-		bodyWriter.Close()
+		if err := json.NewEncoder(&encoded).Encode(body); err != nil {
+			return nil, err
+		}
 	}
 
 	path, err := url.JoinPath(sideshiftV2, endpoint)
@@ -46,14 +49,8 @@ func request[T any, U any](c *Client, method, endpoint string, body *T) (*U, err
 		panic(err)
 	}
 
-	req, err := http.NewRequest(method, path, bodyReader)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-sideshift-secret", c.apiSecret)
-
-	res, err := c.client.Do(req)
+	// SideShift has no idempotency keys, so only GETs are safe to send again
+	res, err := c.do(ctx, method, path, encoded.Bytes(), method == http.MethodGet)
 	if err != nil {
 		return nil, err
 	}
@@ -69,15 +66,12 @@ func request[T any, U any](c *Client, method, endpoint string, body *T) (*U, err
 			}
 		}
 
+		// error pages from proxies in front of the API aren't JSON
 		if err := json.NewDecoder(res.Body).Decode(&decoded); err != nil {
-			return nil, err
-		}
-
-		if len(decoded.Error.Message) != 0 {
-			return nil, fmt.Errorf("bad status code %v: %v", res.StatusCode, decoded.Error.Message)
+			return nil, &StatusError{Code: res.StatusCode}
 		}
 
-		return nil, fmt.Errorf("bad status code %v", res.StatusCode)
+		return nil, &StatusError{res.StatusCode, decoded.Error.Message}
 	}
 
 	var decoded U
@@ -88,6 +82,20 @@ func request[T any, U any](c *Client, method, endpoint string, body *T) (*U, err
 	return &decoded, nil
 }
 
+// do sends a request, trying again on rate limits, server errors and network failures if retry is set.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, retry bool) (*http.Response, error) {
+	return retryPolicy.Do(ctx, retry, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-sideshift-secret", c.apiSecret)
+
+		return c.client.Do(req)
+	})
+}
+
 type Client struct {
 	client    *http.Client
 	apiSecret string
@@ -97,8 +105,8 @@ func NewClient(apiSecret string) *Client {
 	return &Client{&http.Client{}, apiSecret}
 }
 
-func (c *Client) CanShift() (bool, error) {
-	perms, err := c.GetPermissions()
+func (c *Client) CanShift(ctx context.Context) (bool, error) {
+	perms, err := c.GetPermissions(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -106,8 +114,8 @@ func (c *Client) CanShift() (bool, error) {
 	return perms.CreateShift, nil
 }
 
-func (c *Client) GetPermissions() (*PermissionsResponse, error) {
-	res, err := request[struct{}, PermissionsResponse](c, http.MethodGet, "/permissions", nil)
+func (c *Client) GetPermissions(ctx context.Context) (*PermissionsResponse, error) {
+	res, err := request[struct{}, PermissionsResponse](ctx, c, http.MethodGet, "/permissions", nil)
 	if err != nil {
 		return nil, fmt.Errorf("while getting permissions: %w", err)
 	}
@@ -115,13 +123,13 @@ func (c *Client) GetPermissions() (*PermissionsResponse, error) {
 	return res, nil
 }
 
-func (c *Client) GetShift(shiftID string) (*ShiftResponse, error) {
+func (c *Client) GetShift(ctx context.Context, shiftID string) (*ShiftResponse, error) {
 	path, err := url.JoinPath("/shifts", url.PathEscape(shiftID))
 	if err != nil {
 		panic(err)
 	}
 
-	res, err := request[struct{}, ShiftResponse](c, http.MethodGet, path, nil)
+	res, err := request[struct{}, ShiftResponse](ctx, c, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("while getting shift: %w", err)
 	}
@@ -129,10 +137,12 @@ func (c *Client) GetShift(shiftID string) (*ShiftResponse, error) {
 	return res, nil
 }
 
-func (c *Client) GetPair(base, settle string) (*PairResponse, error) {
+// GetPair gets the limits and rate for shifting base to settle. Either may be given as coin-network, e.g. usdc-base,
+// to pick a network other than the coin's default.
+func (c *Client) GetPair(ctx context.Context, base, settle string) (*PairResponse, error) {
 	path := fmt.Sprintf("/pair/%v/%v", url.PathEscape(base), url.PathEscape(settle))
 
-	res, err := request[struct{}, PairResponse](c, http.MethodGet, path, nil)
+	res, err := request[struct{}, PairResponse](ctx, c, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("while getting pair: %w", err)
 	}
@@ -140,8 +150,8 @@ func (c *Client) GetPair(base, settle string) (*PairResponse, error) {
 	return res, nil
 }
 
-func (c *Client) CreateFixedShift(shift FixedShiftRequest) (*FixedShiftResponse, error) {
-	res, err := request[FixedShiftRequest, FixedShiftResponse](c, http.MethodPost, "/shifts/fixed", &shift)
+func (c *Client) CreateFixedShift(ctx context.Context, shift FixedShiftRequest) (*FixedShiftResponse, error) {
+	res, err := request[FixedShiftRequest, FixedShiftResponse](ctx, c, http.MethodPost, "/shifts/fixed", &shift)
 	if err != nil {
 		return nil, fmt.Errorf("while creating fixed shift: %w", err)
 	}
@@ -149,8 +159,17 @@ func (c *Client) CreateFixedShift(shift FixedShiftRequest) (*FixedShiftResponse,
 	return res, nil
 }
 
-func (c *Client) CreateQuote(quote QuoteRequest) (*QuoteResponse, error) {
-	res, err := request[QuoteRequest, QuoteResponse](c, http.MethodPost, "/quotes", &quote)
+func (c *Client) CreateVariableShift(ctx context.Context, shift VariableShiftRequest) (*VariableShiftResponse, error) {
+	res, err := request[VariableShiftRequest, VariableShiftResponse](ctx, c, http.MethodPost, "/shifts/variable", &shift)
+	if err != nil {
+		return nil, fmt.Errorf("while creating variable shift: %w", err)
+	}
+
+	return res, nil
+}
+
+func (c *Client) CreateQuote(ctx context.Context, quote QuoteRequest) (*QuoteResponse, error) {
+	res, err := request[QuoteRequest, QuoteResponse](ctx, c, http.MethodPost, "/quotes", &quote)
 	if err != nil {
 		return nil, fmt.Errorf("while creating quote: %w", err)
 	}
@@ -158,29 +177,125 @@ func (c *Client) CreateQuote(quote QuoteRequest) (*QuoteResponse, error) {
 	return res, nil
 }
 
-func (c *Client) PollShift(shiftID string) (shift *ShiftResponse, err error) {
-Loop:
-	for range time.Tick(10 * time.Second) {
-		shift, err = c.GetShift(shiftID)
+// PollOpts configures how PollShiftWithOpts checks on a shift.
+type PollOpts struct {
+	// How often to check the shift. Defaults to 10 seconds.
+	Interval time.Duration
+	// Longest to wait between checks while requests are failing. The wait doubles from Interval with each consecutive
+	// failure. Defaults to 5 minutes.
+	MaxBackoff time.Duration
+	// Give up after this long. Zero waits until ctx is done.
+	Timeout time.Duration
+	// Called with the shift whenever its status changes, starting with the first status seen.
+	OnStatus func(shift *ShiftResponse)
+}
+
+// PollShift waits for a shift to finish. A settled shift is returned as is, any other outcome is returned with an
+// error: ErrShiftExpired, *RefundedError or *MultipleDepositsError.
+func (c *Client) PollShift(ctx context.Context, shiftID string) (*ShiftResponse, error) {
+	return c.PollShiftWithOpts(ctx, shiftID, PollOpts{})
+}
+
+// PollShiftWithOpts is PollShift with control over the polling. Transient errors are retried with backoff rather
+// than returned.
+func (c *Client) PollShiftWithOpts(ctx context.Context, shiftID string, opts PollOpts) (*ShiftResponse, error) {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	maxBackoff := opts.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var (
+		status string
+		wait   = interval
+		timer  = time.NewTimer(0)
+	)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("while polling shift %v: %w", shiftID, ctx.Err())
+		case <-timer.C:
+		}
+
+		shift, err := c.GetShift(ctx, shiftID)
 		if err != nil {
-			return nil, err
+			if !isTransient(err) || ctx.Err() != nil {
+				return nil, err
+			}
+			if wait *= 2; wait > maxBackoff {
+				wait = maxBackoff
+			}
+			timer.Reset(wait)
+			continue
 		}
+		wait = interval
 
-		if time.Now().After(shift.ExpiresAt) {
-			// TODO: return error?
-			return shift, nil
+		if shift.Status != status {
+			status = shift.Status
+			if opts.OnStatus != nil {
+				opts.OnStatus(shift)
+			}
 		}
 
-		switch status := shift.Status; status {
-		case StatusWaiting, StatusPending, StatusProcessing, StatusSettling:
-			continue
-		case StatusSettled:
-			// OK, all done
-			break Loop
-		default:
-			return nil, fmt.Errorf("unknown shift status %v", status)
+		if done, err := shiftOutcome(shift, time.Now()); done {
+			return shift, err
 		}
+		timer.Reset(interval)
+	}
+}
+
+// isTransient reports whether a request that failed with err is worth trying again.
+func isTransient(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= http.StatusInternalServerError
 	}
+	// anything else failed before we got a response
+	return true
+}
 
-	return shift, nil
+// shiftOutcome works out whether shift has finished as of now, and if so how.
+func shiftOutcome(shift *ShiftResponse, now time.Time) (done bool, err error) {
+	switch status := shift.Status; status {
+	case StatusWaiting:
+		// once a deposit has been seen the shift carries on past its expiry
+		if now.After(shift.ExpiresAt) {
+			return true, ErrShiftExpired
+		}
+		return false, nil
+	case StatusExpired:
+		return true, ErrShiftExpired
+	case StatusPending, StatusProcessing, StatusSettling:
+		return false, nil
+	case StatusReview, StatusRefund, StatusRefunding:
+		// held for manual review or waiting on a refund to go out, either way SideShift still has to act
+		return false, nil
+	case StatusSettled:
+		return true, nil
+	case StatusRefunded:
+		amount := shift.RefundAmount
+		if amount.IsZero() {
+			amount = shift.DepositAmount
+		}
+		return true, &RefundedError{Shift: shift, TxHash: shift.RefundHash, Amount: amount}
+	case StatusMultiple:
+		for _, deposit := range shift.Deposits {
+			if deposit.Status != StatusSettled && deposit.Status != StatusRefunded {
+				return false, nil
+			}
+		}
+		return true, &MultipleDepositsError{Shift: shift, Deposits: shift.Deposits}
+	default:
+		return true, fmt.Errorf("unknown shift status %v", status)
+	}
 }
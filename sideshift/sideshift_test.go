@@ -0,0 +1,170 @@
+package sideshift
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollShiftCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := NewClient("123")
+	_, err := client.PollShift(ctx, "123")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestShiftOutcome(t *testing.T) {
+	now := time.Now()
+
+	for _, status := range []string{StatusPending, StatusProcessing, StatusSettling, StatusReview, StatusRefund, StatusRefunding} {
+		done, err := shiftOutcome(&ShiftResponse{Status: status}, now)
+		assert.False(t, done, status)
+		assert.Nil(t, err, status)
+	}
+
+	done, err := shiftOutcome(&ShiftResponse{Status: StatusWaiting, ExpiresAt: now.Add(time.Minute)}, now)
+	assert.False(t, done)
+	assert.Nil(t, err)
+
+	done, err = shiftOutcome(&ShiftResponse{Status: StatusWaiting, ExpiresAt: now.Add(-time.Minute)}, now)
+	assert.True(t, done)
+	assert.ErrorIs(t, err, ErrShiftExpired)
+
+	done, err = shiftOutcome(&ShiftResponse{Status: StatusExpired, ExpiresAt: now.Add(-time.Minute)}, now)
+	assert.True(t, done)
+	assert.ErrorIs(t, err, ErrShiftExpired)
+
+	done, err = shiftOutcome(&ShiftResponse{Status: StatusSettled}, now)
+	assert.True(t, done)
+	assert.Nil(t, err)
+
+	done, err = shiftOutcome(&ShiftResponse{Status: StatusRefunded, DepositAmount: decimal.RequireFromString("1.5"), RefundHash: "hash"}, now)
+	assert.True(t, done)
+	var refunded *RefundedError
+	assert.ErrorAs(t, err, &refunded)
+	assert.Equal(t, "hash", refunded.TxHash)
+	assert.Equal(t, "1.5", refunded.Amount.String())
+
+	deposits := []Deposit{
+		{Status: StatusSettled, SettleHash: "settle"},
+		{Status: StatusRefunding},
+	}
+	done, err = shiftOutcome(&ShiftResponse{Status: StatusMultiple, Deposits: deposits}, now)
+	assert.False(t, done)
+	assert.Nil(t, err)
+
+	deposits[1] = Deposit{Status: StatusRefunded, RefundHash: "refund"}
+	done, err = shiftOutcome(&ShiftResponse{Status: StatusMultiple, Deposits: deposits}, now)
+	assert.True(t, done)
+	var multiple *MultipleDepositsError
+	assert.ErrorAs(t, err, &multiple)
+	assert.Len(t, multiple.Deposits, 2)
+	assert.Contains(t, err.Error(), "settle")
+	assert.Contains(t, err.Error(), "refund")
+
+	done, err = shiftOutcome(&ShiftResponse{Status: "bogus"}, now)
+	assert.True(t, done)
+	assert.NotNil(t, err)
+}
+
+func stubShifts(t *testing.T, responses ...string) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := responses[len(responses)-1]
+		if calls < len(responses) {
+			res = responses[calls]
+		}
+		calls++
+
+		if strings.HasPrefix(res, "5") || strings.HasPrefix(res, "4") {
+			code, _ := strconv.Atoi(res)
+			w.WriteHeader(code)
+			return
+		}
+		w.Write([]byte(res))
+	}))
+	t.Cleanup(server.Close)
+
+	oldURL, oldDelay := sideshiftV2, retryPolicy.BaseDelay
+	sideshiftV2 = server.URL
+	retryPolicy.BaseDelay = time.Millisecond
+	t.Cleanup(func() {
+		sideshiftV2 = oldURL
+		retryPolicy.BaseDelay = oldDelay
+	})
+}
+
+func TestPollShiftWithOpts(t *testing.T) {
+	stubShifts(t,
+		`503`,
+		`{"id":"1","status":"waiting","expiresAt":"2100-01-01T00:00:00Z"}`,
+		`{"id":"1","status":"processing"}`,
+		`{"id":"1","status":"processing"}`,
+		`{"id":"1","status":"settled","settleHash":"hash"}`,
+	)
+
+	var statuses []string
+	client := NewClient("123")
+	shift, err := client.PollShiftWithOpts(context.Background(), "1", PollOpts{
+		Interval:   time.Millisecond,
+		MaxBackoff: time.Millisecond,
+		OnStatus: func(shift *ShiftResponse) {
+			statuses = append(statuses, shift.Status)
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "hash", shift.SettleHash)
+	assert.Equal(t, []string{StatusWaiting, StatusProcessing, StatusSettled}, statuses)
+}
+
+func TestPollShiftWithOptsNotFound(t *testing.T) {
+	stubShifts(t, `404`)
+
+	client := NewClient("123")
+	_, err := client.PollShiftWithOpts(context.Background(), "1", PollOpts{Interval: time.Millisecond})
+
+	var statusErr *StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.Code)
+}
+
+func TestPollShiftWithOptsTimeout(t *testing.T) {
+	stubShifts(t, `{"id":"1","status":"processing"}`)
+
+	client := NewClient("123")
+	_, err := client.PollShiftWithOpts(context.Background(), "1", PollOpts{
+		Interval: time.Millisecond,
+		Timeout:  20 * time.Millisecond,
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNoRetryPost(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	old := sideshiftV2
+	sideshiftV2 = server.URL
+	t.Cleanup(func() { sideshiftV2 = old })
+
+	client := NewClient("123")
+	_, err := client.CreateQuote(context.Background(), QuoteRequest{})
+
+	var statusErr *StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.Code)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,13 @@
+package walletrpc
+
+type rpcRequest[T any] struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      string `json:"id"`
+	Method  string `json:"method"`
+	Params  *T     `json:"params,omitempty"`
+}
+
+type GetTransferByTxIDRequest struct {
+	TxID         string `json:"txid"`
+	AccountIndex uint32 `json:"account_index"`
+}
@@ -0,0 +1,48 @@
+package walletrpc
+
+import (
+	"math/big"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	TransferIn      = "in"
+	TransferOut     = "out"
+	TransferPending = "pending"
+	TransferFailed  = "failed"
+	TransferPool    = "pool"
+)
+
+// Returned for both malformed and unknown transaction IDs.
+const errCodeWrongTxID = -8
+
+type rpcResponse[T any] struct {
+	ID     string `json:"id"`
+	Result *T     `json:"result"`
+	Error  *Error `json:"error"`
+}
+
+type GetTransferByTxIDResponse struct {
+	Transfer  Transfer   `json:"transfer"`
+	Transfers []Transfer `json:"transfers"`
+}
+
+type Transfer struct {
+	TxID          string `json:"txid"`
+	Address       string `json:"address"`
+	Amount        uint64 `json:"amount"`
+	Confirmations uint64 `json:"confirmations"`
+	Height        uint64 `json:"height"`
+	PaymentID     string `json:"payment_id"`
+	Type          string `json:"type"`
+	SubaddrIndex  struct {
+		Major uint32 `json:"major"`
+		Minor uint32 `json:"minor"`
+	} `json:"subaddr_index"`
+}
+
+// XMR returns the amount in XMR rather than piconero.
+func (t *Transfer) XMR() decimal.Decimal {
+	return decimal.NewFromBigInt(new(big.Int).SetUint64(t.Amount), -atomicExponent)
+}
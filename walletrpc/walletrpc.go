@@ -0,0 +1,130 @@
+package walletrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Monero amounts are in piconero, 10^-12 XMR.
+const atomicExponent = 12
+
+var ErrTransferNotFound = errors.New("transfer not found")
+
+// Overridden in tests.
+var (
+	pollInterval = 20 * time.Second
+)
+
+// Error is an error returned by the wallet.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("wallet error %v: %v", e.Code, e.Message)
+}
+
+func call[T any, U any](ctx context.Context, c *Client, method string, params *T) (*U, error) {
+	body, err := json.Marshal(rpcRequest[T]{
+		JSONRPC: "2.0",
+		ID:      "0",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	// drain body so TCP conn can be reused
+	defer io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("bad status code %v", res.StatusCode)
+	}
+
+	var decoded rpcResponse[U]
+	if err := json.NewDecoder(res.Body).Decode(&decoded); err != nil {
+		return nil, err
+	}
+	if decoded.Error != nil {
+		return nil, decoded.Error
+	}
+	if decoded.Result == nil {
+		return nil, fmt.Errorf("empty result for %v", method)
+	}
+
+	return decoded.Result, nil
+}
+
+// Client talks to a monero-wallet-rpc instance. The wallet must be started with --disable-rpc-login, since digest
+// authentication isn't supported.
+type Client struct {
+	client *http.Client
+	url    string
+}
+
+// NewClient returns a client for the JSON-RPC endpoint at url, usually http://127.0.0.1:18082/json_rpc.
+func NewClient(url string) *Client {
+	return &Client{&http.Client{}, url}
+}
+
+func (c *Client) GetTransferByTxID(ctx context.Context, txID string, account uint32) (*Transfer, error) {
+	res, err := call[GetTransferByTxIDRequest, GetTransferByTxIDResponse](ctx, c, "get_transfer_by_txid", &GetTransferByTxIDRequest{
+		TxID:         txID,
+		AccountIndex: account,
+	})
+
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == errCodeWrongTxID {
+		return nil, fmt.Errorf("while getting transfer %v: %w", txID, ErrTransferNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("while getting transfer %v: %w", txID, err)
+	}
+
+	return &res.Transfer, nil
+}
+
+// WaitForTransfer waits until the incoming transfer in txID has at least confirmations confirmations. Transfers the
+// wallet hasn't seen yet are waited for too, since it only scans for new blocks every so often.
+func (c *Client) WaitForTransfer(ctx context.Context, txID string, account uint32, confirmations uint64) (*Transfer, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		transfer, err := c.GetTransferByTxID(ctx, txID, account)
+		switch {
+		case errors.Is(err, ErrTransferNotFound):
+		case err != nil:
+			return nil, err
+		case transfer.Type != TransferIn && transfer.Type != TransferPool:
+			return nil, fmt.Errorf("transaction %v is not an incoming transfer (type %v)", txID, transfer.Type)
+		case transfer.Type == TransferIn && transfer.Confirmations >= confirmations:
+			return transfer, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
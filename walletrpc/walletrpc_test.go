@@ -0,0 +1,75 @@
+package walletrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubWallet answers get_transfer_by_txid with each of responses in turn, repeating the last one.
+func stubWallet(t *testing.T, responses ...string) *httptest.Server {
+	calls := 0
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest[GetTransferByTxIDRequest]
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "get_transfer_by_txid", req.Method)
+		assert.Equal(t, "abc", req.Params.TxID)
+		assert.Equal(t, uint32(1), req.Params.AccountIndex)
+
+		res := responses[len(responses)-1]
+		if calls < len(responses) {
+			res = responses[calls]
+		}
+		calls++
+
+		w.Write([]byte(res))
+	}))
+}
+
+func TestWaitForTransfer(t *testing.T) {
+	pollInterval = time.Millisecond
+
+	server := stubWallet(t,
+		`{"id":"0","jsonrpc":"2.0","error":{"code":-8,"message":"Transaction not found."}}`,
+		`{"id":"0","jsonrpc":"2.0","result":{"transfer":{"txid":"abc","amount":1500000000000,"confirmations":0,"type":"pool"}}}`,
+		`{"id":"0","jsonrpc":"2.0","result":{"transfer":{"txid":"abc","amount":1500000000000,"confirmations":3,"type":"in"}}}`,
+		`{"id":"0","jsonrpc":"2.0","result":{"transfer":{"txid":"abc","amount":1500000000000,"confirmations":10,"type":"in"}}}`,
+	)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	transfer, err := client.WaitForTransfer(context.Background(), "abc", 1, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(10), transfer.Confirmations)
+	assert.Equal(t, "1.5", transfer.XMR().String())
+}
+
+func TestWaitForTransferOutgoing(t *testing.T) {
+	pollInterval = time.Millisecond
+
+	server := stubWallet(t, `{"id":"0","jsonrpc":"2.0","result":{"transfer":{"txid":"abc","amount":1,"type":"out"}}}`)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	_, err := client.WaitForTransfer(context.Background(), "abc", 1, 10)
+	assert.NotNil(t, err)
+}
+
+func TestGetTransferByTxIDError(t *testing.T) {
+	server := stubWallet(t, `{"id":"0","jsonrpc":"2.0","error":{"code":-13,"message":"No wallet file"}}`)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	_, err := client.GetTransferByTxID(context.Background(), "abc", 1)
+
+	var rpcErr *Error
+	assert.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, -13, rpcErr.Code)
+	assert.NotErrorIs(t, err, ErrTransferNotFound)
+}